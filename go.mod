@@ -5,7 +5,6 @@ go 1.21
 require (
 	github.com/ghodss/yaml v1.0.0
 	github.com/google/go-querystring v1.1.0
-	github.com/grafana/loki v1.6.2-0.20230629190929-4b53909ccf0d
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/olekukonko/tablewriter v0.0.5
 	github.com/prometheus/client_golang v1.16.0
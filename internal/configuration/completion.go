@@ -0,0 +1,325 @@
+package configuration
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// CompleteCommand is the hidden command called by the completion scripts
+// to get the dynamic values of a flag: tracer __complete <flag> [flags]
+const CompleteCommand = "__complete"
+
+// dynamicFlags are the flags whose values are completed through CompleteCommand
+var dynamicFlags = map[string]bool{
+	"service": true,
+	"stack":   true,
+}
+
+// fileFlags are the flags whose values are paths
+var fileFlags = map[string]bool{
+	"log-dir":             true,
+	"monitoring-ca-path":  true,
+	"monitoring-cert":     true,
+	"monitoring-cert-key": true,
+	"out":                 true,
+	"profile-file":        true,
+}
+
+// subcommand is a command of tracer and its subcommands
+type subcommand struct {
+	Name        string
+	Subcommands []string
+}
+
+// subcommands are the commands of tracer
+var subcommands = []subcommand{
+	{"traces", []string{"search"}},
+	{"trace", []string{"export", "diff"}},
+	{"graph", nil},
+	{"logs", []string{"stats"}},
+	{"labels", nil},
+	{"completion", []string{"bash", "zsh", "fish"}},
+}
+
+// completionFlag describes a flag for the completion scripts
+type completionFlag struct {
+	Name    string
+	Desc    string
+	Bool    bool
+	Repeat  bool
+	Values  []string
+	Dynamic bool
+	File    bool
+}
+
+// completionShorthand is a shorthand of a flag for the completion scripts
+type completionShorthand struct {
+	Short string
+	Long  string
+}
+
+// completionFlags returns the flags declared by the Configuration sorted by name
+func completionFlags() []completionFlag {
+
+	flags := []completionFlag{}
+
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+
+			field := t.Field(i)
+			name := field.Tag.Get("mapstructure")
+
+			if field.Anonymous && strings.HasSuffix(name, ",squash") {
+				walk(field.Type)
+				continue
+			}
+
+			if name == "" {
+				continue
+			}
+
+			flag := completionFlag{
+				Name:    name,
+				Desc:    field.Tag.Get("desc"),
+				Bool:    field.Type.Kind() == reflect.Bool,
+				Repeat:  field.Type.Kind() == reflect.Slice,
+				Dynamic: dynamicFlags[name],
+				File:    fileFlags[name],
+			}
+
+			if allowed := field.Tag.Get("allowed"); allowed != "" {
+				flag.Values = strings.Split(allowed, ",")
+			}
+
+			flags = append(flags, flag)
+		}
+	}
+	walk(reflect.TypeOf(Configuration{}))
+
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+
+	return flags
+}
+
+// CompletionScript returns the completion script for the given shell
+func CompletionScript(shell string) (string, error) {
+
+	tmpl, ok := completionTemplates[shell]
+	if !ok {
+		return "", fmt.Errorf("unable to generate completion script: unsupported shell %s, must be one of bash, zsh or fish", shell)
+	}
+
+	shorts := make([]completionShorthand, 0, len(shorthands))
+	for short, long := range shorthands {
+		shorts = append(shorts, completionShorthand{Short: strings.TrimPrefix(short, "-"), Long: strings.TrimPrefix(long, "--")})
+	}
+	sort.Slice(shorts, func(i, j int) bool { return shorts[i].Short < shorts[j].Short })
+
+	data := struct {
+		Command     string
+		Flags       []completionFlag
+		Shorthands  []completionShorthand
+		Subcommands []subcommand
+	}{
+		Command:     CompleteCommand,
+		Flags:       completionFlags(),
+		Shorthands:  shorts,
+		Subcommands: subcommands,
+	}
+
+	out := &bytes.Buffer{}
+	if err := template.Must(template.New(shell).Funcs(completionFuncs).Parse(tmpl)).Execute(out, data); err != nil {
+		return "", fmt.Errorf("unable to generate completion script: %w", err)
+	}
+
+	return out.String(), nil
+}
+
+// completionFuncs are the helpers of the completion templates
+var completionFuncs = template.FuncMap{
+	"join": strings.Join,
+	// zshDesc escapes a description for a zsh _arguments spec in single quotes
+	"zshDesc": strings.NewReplacer(`'`, `'\''`, `[`, `\[`, `]`, `\]`, `:`, `\:`).Replace,
+	// fishDesc escapes a description for fish in single quotes
+	"fishDesc": strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace,
+}
+
+var completionTemplates = map[string]string{
+	"bash": `# bash completion for tracer, load it with: source <(tracer completion bash)
+
+_tracer_value_flags="{{range .Flags}}{{if not .Bool}}--{{.Name}} {{end}}{{end}}{{range $i, $s := .Shorthands}}{{if $i}} {{end}}-{{$s.Short}}{{end}}"
+
+_tracer_values() {
+    local name="$1" args=()
+    shift
+
+    # --flag=value is split in three words by bash, join them back
+    while (($#)); do
+        if [[ "$2" == "=" ]]; then
+            args+=("$1=$3")
+            shift $(($# < 3 ? $# : 3))
+        else
+            args+=("$1")
+            shift
+        fi
+    done
+
+    tracer {{.Command}} "$name" "${args[@]}" 2>/dev/null
+}
+
+_tracer() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}" last=$((COMP_CWORD - 1))
+
+    # --flag=value is split in three words by bash
+    if [[ "$cur" == "=" ]]; then
+        cur=""
+    elif [[ "$prev" == "=" ]]; then
+        prev="${COMP_WORDS[COMP_CWORD-2]}"
+        last=$((COMP_CWORD - 2))
+    fi
+
+    case "$prev" in
+{{- range .Flags}}{{if .Values}}
+    --{{.Name}})
+        COMPREPLY=($(compgen -W "{{join .Values " "}}" -- "$cur"))
+        return
+        ;;
+{{- else if .Dynamic}}
+    --{{.Name}})
+        COMPREPLY=($(compgen -W "$(_tracer_values {{.Name}} "${COMP_WORDS[@]:1:last-1}")" -- "$cur"))
+        return
+        ;;
+{{- end}}{{end}}
+    esac
+
+    if [[ " $_tracer_value_flags " == *" $prev "* ]]; then
+        return
+    fi
+
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=($(compgen -W "{{range $i, $f := .Flags}}{{if $i}} {{end}}--{{$f.Name}}{{end}}" -- "$cur"))
+        return
+    fi
+
+    local i word args=()
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        word="${COMP_WORDS[i]}"
+        case "$word" in
+        -*)
+            if [[ " $_tracer_value_flags " == *" $word "* ]]; then
+                ((i++))
+                [[ "${COMP_WORDS[i]}" == "=" ]] && ((i++))
+            fi
+            ;;
+        *)
+            args+=("$word")
+            ;;
+        esac
+    done
+
+    case "${#args[@]}:${args[0]}" in
+    0:)
+        COMPREPLY=($(compgen -W "{{range $i, $c := .Subcommands}}{{if $i}} {{end}}{{$c.Name}}{{end}}" -- "$cur"))
+        ;;
+{{- range .Subcommands}}{{if .Subcommands}}
+    1:{{.Name}})
+        COMPREPLY=($(compgen -W "{{join .Subcommands " "}}" -- "$cur"))
+        ;;
+{{- end}}{{end}}
+    esac
+}
+
+complete -o default -F _tracer tracer
+`,
+
+	"zsh": `#compdef tracer
+# zsh completion for tracer, load it with: source <(tracer completion zsh)
+
+_tracer_values() {
+    local -a values
+    values=(${(f)"$(tracer {{.Command}} $1 ${words[2,CURRENT-2]} 2>/dev/null)"})
+    compadd -a values
+}
+
+_tracer() {
+    local state line
+
+    _arguments \
+{{- range .Flags}}
+        '{{if .Repeat}}*{{end}}--{{.Name}}{{if not .Bool}}={{end}}[{{zshDesc .Desc}}]{{if .Values}}:{{.Name}}:({{join .Values " "}}){{else if .Dynamic}}:{{.Name}}:_tracer_values {{.Name}}{{else if .File}}:{{.Name}}:_files{{else if not .Bool}}:{{.Name}}: {{end}}' \
+{{- end}}
+{{- range .Shorthands}}
+        '-{{.Short}}+[Same as --{{.Long}}]:{{.Long}}: ' \
+{{- end}}
+        '1:command:({{range $i, $c := .Subcommands}}{{if $i}} {{end}}{{$c.Name}}{{end}})' \
+        '2:subcommand:->subcommand' \
+        '*:argument: '
+
+    case $state in
+    subcommand)
+        case $line[1] in
+{{- range .Subcommands}}{{if .Subcommands}}
+        {{.Name}}) compadd {{join .Subcommands " "}} ;;
+{{- end}}{{end}}
+        esac
+        ;;
+    esac
+}
+
+if [ "$funcstack[1]" = "_tracer" ]; then
+    _tracer "$@"
+else
+    compdef _tracer tracer
+fi
+`,
+
+	"fish": `# fish completion for tracer, load it with: tracer completion fish | source
+
+function __tracer_values
+    set -l tokens (commandline -opc)
+    set -e tokens[-1]
+    set -e tokens[1]
+    tracer {{.Command}} $argv[1] $tokens 2>/dev/null
+end
+
+function __tracer_args
+    set -l tokens (commandline -opc)
+    set -e tokens[1]
+    set -l args
+    set -l skip 0
+    for token in $tokens
+        if test $skip -eq 1
+            set skip 0
+        else if contains -- $token {{range .Flags}}{{if not .Bool}}--{{.Name}} {{end}}{{end}}{{range $i, $s := .Shorthands}}{{if $i}} {{end}}-{{$s.Short}}{{end}}
+            set skip 1
+        else if not string match -q -- '-*' $token
+            set -a args $token
+        end
+    end
+    echo (count $args) $args
+end
+
+function __tracer_needs
+    test (__tracer_args) = "$argv"
+end
+
+complete -c tracer -f
+{{- range .Flags}}
+complete -c tracer -l {{.Name}}{{if .Values}} -x -a '{{join .Values " "}}'{{else if .Dynamic}} -x -a '(__tracer_values {{.Name}})'{{else if .File}} -r -F{{else if not .Bool}} -x{{end}} -d '{{fishDesc .Desc}}'
+{{- end}}
+{{- range .Shorthands}}
+complete -c tracer -s {{.Short}} -x -d 'Same as --{{.Long}}'
+{{- end}}
+{{- range .Subcommands}}
+complete -c tracer -n '__tracer_needs 0' -a {{.Name}}
+{{- end}}
+{{- range .Subcommands}}{{$name := .Name}}{{range .Subcommands}}
+complete -c tracer -n '__tracer_needs 1 {{$name}}' -a {{.}}
+{{- end}}{{end}}
+`,
+}
@@ -0,0 +1,83 @@
+package configuration
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompletionScript(t *testing.T) {
+
+	tests := []struct {
+		shell   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			"bash",
+			[]string{
+				`compgen -W "forward backward"`,
+				`args+=("$1=$3")`,
+				`tracer __complete "$name" "${args[@]}"`,
+				`_tracer_values service "${COMP_WORDS[@]:1:last-1}"`,
+				`_tracer_values stack "${COMP_WORDS[@]:1:last-1}"`,
+				`compgen -W "traces trace graph logs labels completion"`,
+				`1:trace)
+        COMPREPLY=($(compgen -W "export diff" -- "$cur"))`,
+				`1:logs)
+        COMPREPLY=($(compgen -W "stats" -- "$cur"))`,
+				`complete -o default -F _tracer tracer`,
+			},
+			false,
+		},
+		{
+			"zsh",
+			[]string{
+				`'--direction=[Logs\: Direction of the logs]:direction:(forward backward)'`,
+				`tracer __complete $1`,
+				`:service:_tracer_values service'`,
+				`:stack:_tracer_values stack'`,
+				`'*--service=[`,
+				`'-A+[Same as --after-context]:after-context: '`,
+				`'1:command:(traces trace graph logs labels completion)'`,
+				`trace) compadd export diff ;;`,
+				`completion) compadd bash zsh fish ;;`,
+			},
+			false,
+		},
+		{
+			"fish",
+			[]string{
+				`complete -c tracer -l direction -x -a 'forward backward'`,
+				`tracer __complete $argv[1] $tokens`,
+				`complete -c tracer -l service -x -a '(__tracer_values service)'`,
+				`complete -c tracer -l stack -x -a '(__tracer_values stack)'`,
+				`complete -c tracer -l out -r -F`,
+				`complete -c tracer -n '__tracer_needs 0' -a labels`,
+				`complete -c tracer -n '__tracer_needs 1 traces' -a search`,
+				`complete -c tracer -n '__tracer_needs 1 trace' -a diff`,
+			},
+			false,
+		},
+		{
+			"tcsh",
+			nil,
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+
+			script, err := CompletionScript(tt.shell)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CompletionScript() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(script, want) {
+					t.Errorf("CompletionScript() does not contain %s", want)
+				}
+			}
+		})
+	}
+}
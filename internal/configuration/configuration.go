@@ -3,6 +3,7 @@ package configuration
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	"go.aporeto.io/addedeffect/lombric"
@@ -32,34 +33,78 @@ type LoggingConf struct {
 
 // TimeWindow is the configuration for queries
 type TimeWindow struct {
-	From  string        `mapstructure:"from"   desc:"From date"`
-	To    string        `mapstructure:"to"     desc:"To date"`
-	Since time.Duration `mapstructure:"since"  desc:"Since duration (will compute From and To with currrent date)" default:"1h"`
+	From   string        `mapstructure:"from"   desc:"From date ex: 2020-10-22T17:56:17Z, 2020-10-22 17:56, 1603389377, now-2h or 10 minutes ago"`
+	To     string        `mapstructure:"to"     desc:"To date, same formats as --from"`
+	Since  time.Duration `mapstructure:"since"  desc:"Since duration (will compute From and To with currrent date)" default:"1h"`
+	Around string        `mapstructure:"around" desc:"Center the window on that date, same formats as --from"`
+	Window time.Duration `mapstructure:"window" desc:"The duration of the window centered on --around" default:"10m"`
+	TZ     string        `mapstructure:"tz"     desc:"The time zone of the dates without one: local, UTC, a name like Europe/Paris or an offset like +02:00" default:"local"`
 }
 
 // TraceConf is the configuration related to traces
 type TraceConf struct {
-	Namespace   string        `mapstructure:"namespace" desc:"Traces: Lookg for traces matching that namespace"`
-	OnlyError   bool          `mapstructure:"errors-only" desc:"Traces: Look only for trace in error"`
-	MinDuration time.Duration `mapstructure:"slower-than" desc:"Traces: Look for traces slower than the provided duration"`
-	Limit       int           `mapstructure:"limit" desc:"Traces: The number of traces to display" default:"1"`
+	OnlyError     bool          `mapstructure:"errors-only" desc:"Traces: Look only for trace in error"`
+	MinDuration   time.Duration `mapstructure:"slower-than" desc:"Traces: Look for traces slower than the provided duration"`
+	MaxDuration   time.Duration `mapstructure:"faster-than" desc:"Traces: Look for traces faster than the provided duration"`
+	Tags          []string      `mapstructure:"tag" desc:"Traces: Look for traces with the tag key=value (repeatable)"`
+	SpanOperation string        `mapstructure:"operation" desc:"Traces: Look for traces with a span matching that operation name"`
+	Limit         int           `mapstructure:"limit" desc:"Traces: The number of traces to display" default:"1"`
+	Concurrency   int           `mapstructure:"concurrency" desc:"Traces: The number of concurrent trace lookups" default:"10"`
+	RateLimit     int           `mapstructure:"rate-limit" desc:"Traces: The maximum number of requests per second to the tracing backend (0 for unlimited)" default:"20"`
+	ExportFormat  string        `mapstructure:"format" desc:"Traces: The format of the exported traces" default:"jaeger" allowed:"jaeger,otlp,zipkin"`
+	ExportDir     string        `mapstructure:"out" desc:"Traces: The directory to export the traces to" default:"."`
+	GraphSource   string        `mapstructure:"graph-source" desc:"Traces: The source of the service graph" default:"traces" allowed:"traces,dependencies"`
+	GraphFormat   string        `mapstructure:"graph-format" desc:"Traces: The output format of the service graph" default:"table" allowed:"table,dot,mermaid"`
+	GraphSample   int           `mapstructure:"sample" desc:"Traces: The number of traces to sample per service to build the service graph" default:"20"`
+}
+
+// AnomalyConf is the configuration related to anomalies
+type AnomalyConf struct {
+	Anomalies     bool     `mapstructure:"anomalies" desc:"Anomalies: Score the counts against the same window in the past"`
+	AnomaliesOnly bool     `mapstructure:"anomalies-only" desc:"Anomalies: Only display the rows flagged as anomalies"`
+	Baselines     []string `mapstructure:"baseline" desc:"Anomalies: The offset of a past window to compare with ex:1d,7d (repeatable, default 1d,7d)"`
 }
 
 // FilterConf is the configuration realted to filters
 type FilterConf struct {
-	Codes    string   `mapstructure:"code" desc:"Filters: The code to filter ex:200-300,400-422,500"`
-	Services []string `mapstructure:"service" desc:"Filters: The service to filter (repeatable)"`
-	URLS     []string `mapstructure:"url" desc:"Filters: The url to filter (repeatable)"`
+	Codes      string   `mapstructure:"code" desc:"Filters: The code to filter ex:200-300,400-422,500"`
+	Services   []string `mapstructure:"service" desc:"Filters: The service to filter (repeatable)"`
+	URLS       []string `mapstructure:"url" desc:"Filters: The url to filter (repeatable)"`
+	Identities []string `mapstructure:"identity" desc:"Filters: The identity to filter by name or category (repeatable)"`
+	Operations []string `mapstructure:"elemental-operation" desc:"Filters: The elemental operation to filter ex:create,retrieve-many (repeatable)"`
+	Namespace  string   `mapstructure:"namespace" desc:"Filters: Look for queries matching that namespace"`
+	Recursive  bool     `mapstructure:"recursive" desc:"Filters: Include the children namespaces of --namespace"`
 }
 
 // LogConf is the configuration realted to logs
 type LogConf struct {
-	Direction   string `mapstructure:"direction" desc:"Logs: Direction of the logs" default:"forward" allowed:"forward,backward"`
-	LogFilter   string `mapstructure:"log-filter" desc:"Logs; Optional log filter to append to log query if service flag is used or full logcli filter if not service flag are set"`
-	LogLines    int    `mapstructure:"lines" desc:"Logs: Number of lines to print" default:"10"`
-	Log         bool   `mapstructure:"log" desc:"Logs: Enable log mode to get logs from services"`
-	Follow      bool   `mapstructure:"follow" desc:"Logs: Follow logs stream in almost real time"`
-	LogNoLabels bool   `mapstructure:"no-labels" desc:"Logs: Do not display labels with logs"`
+	Direction     string        `mapstructure:"direction" desc:"Logs: Direction of the logs" default:"forward" allowed:"forward,backward"`
+	LogFilter     string        `mapstructure:"log-filter" desc:"Logs; Optional LogQL filter appended verbatim to the generated query, or full LogQL query the other filters are appended to if no service, pod or container is set"`
+	LogLines      int           `mapstructure:"lines" desc:"Logs: Number of lines to print" default:"10"`
+	Log           bool          `mapstructure:"log" desc:"Logs: Enable log mode to get logs from services"`
+	Follow        bool          `mapstructure:"follow" desc:"Logs: Follow logs stream in almost real time, reconnecting on failures"`
+	LogNoLabels   bool          `mapstructure:"no-labels" desc:"Logs: Do not display labels with logs"`
+	LogOutput     string        `mapstructure:"log-output" desc:"Logs: The output format of the logs" default:"default" allowed:"default,raw,jsonl,logfmt"`
+	Color         string        `mapstructure:"color" desc:"Logs: Colorize the output, auto disables colors when the output is not a terminal" default:"auto" allowed:"always,never,auto"`
+	MinLevel      string        `mapstructure:"min-level" desc:"Logs: Only display the zap JSON lines with at least that level" default:"debug" allowed:"debug,info,warn,error,dpanic,panic,fatal"`
+	Fields        []string      `mapstructure:"field" desc:"Logs: Only display the zap JSON lines with the field key=value (repeatable)"`
+	Pods          []string      `mapstructure:"pod" desc:"Logs: The pod to get the logs from (repeatable)"`
+	Containers    []string      `mapstructure:"container" desc:"Logs: The container to get the logs from (repeatable)"`
+	Contains      []string      `mapstructure:"contains" desc:"Logs: Only display the lines containing that text (repeatable)"`
+	Regexps       []string      `mapstructure:"regex" desc:"Logs: Only display the lines matching that regular expression (repeatable)"`
+	Excludes      []string      `mapstructure:"exclude" desc:"Logs: Do not display the lines containing that text (repeatable)"`
+	JSONFields    []string      `mapstructure:"json-field" desc:"Logs: Only display the JSON lines with the field key=value, filtered by loki (repeatable)"`
+	ShowQuery     bool          `mapstructure:"show-query" desc:"Logs: Print the generated LogQL query on stderr"`
+	Patterns      bool          `mapstructure:"patterns" desc:"Logs: Group all the lines of the window into templates with the variable parts masked"`
+	StatsQuery    string        `mapstructure:"stats-query" desc:"Logs: The LogQL metric query of logs stats, the top services by error lines if empty"`
+	Step          time.Duration `mapstructure:"step" desc:"Logs: The step of the logs stats" default:"1m"`
+	Top           int           `mapstructure:"top" desc:"Logs: The number of services of the built-in logs stats query" default:"10"`
+	Context       int           `mapstructure:"context" desc:"Logs: The number of lines of the same stream to display around each line"`
+	BeforeContext int           `mapstructure:"before-context" desc:"Logs: The number of lines of the same stream to display before each line (-B)"`
+	AfterContext  int           `mapstructure:"after-context" desc:"Logs: The number of lines of the same stream to display after each line (-A)"`
+	LogDir        string        `mapstructure:"log-dir" desc:"Logs: Write all the lines of the window in one file per stream of that directory, fetched by pages of --lines if set"`
+	SplitBy       string        `mapstructure:"split-by" desc:"Logs: How the lines are split in files with --log-dir: pod, app or label:<name>" default:"pod"`
+	Gzip          bool          `mapstructure:"gzip" desc:"Logs: Compress the files written with --log-dir"`
 }
 
 // Configuration hold the service configuration.
@@ -73,6 +118,7 @@ type Configuration struct {
 	TimeWindow     `mapstructure:",squash"`
 	LogConf        `mapstructure:",squash"`
 	TraceConf      `mapstructure:",squash"`
+	AnomalyConf    `mapstructure:",squash"`
 	Help           bool `mapstructure:"help" desc:"Show full help with examples"`
 }
 
@@ -87,6 +133,7 @@ func (c *Configuration) PrintVersion() {
 // NewConfiguration returns a new configuration.
 func NewConfiguration() *Configuration {
 	c := &Configuration{}
+	os.Args = expandShorthands(os.Args)
 	lombric.Initialize(c)
 	logutils.Configure(c.LogLevel, c.LogFormat)
 
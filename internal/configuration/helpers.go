@@ -3,13 +3,64 @@ package configuration
 import (
 	"fmt"
 	"os"
+	"regexp"
 
 	"github.com/spf13/pflag"
 )
 
+// shorthands are the grep style short flags mapped to their long flag
+// as lombric only declares long flags
+var shorthands = map[string]string{
+	"-A": "--after-context",
+	"-B": "--before-context",
+	"-C": "--context",
+}
+
+// shorthandRegexp matches a shorthand alone or followed by its number of lines
+var shorthandRegexp = regexp.MustCompile(`^(-[A-Za-z])(\d*)$`)
+
+// expandShorthands replaces the shorthands by their long flag in the arguments.
+// Only -X and -X<lines> are expanded so the values starting with -X are kept.
+func expandShorthands(args []string) []string {
+
+	expanded := make([]string, 0, len(args))
+
+	for i, arg := range args {
+
+		// Everything after -- is not a flag
+		if arg == "--" {
+			return append(expanded, args[i:]...)
+		}
+
+		if m := shorthandRegexp.FindStringSubmatch(arg); m != nil {
+			if long, ok := shorthands[m[1]]; ok {
+				if m[2] != "" {
+					arg = long + "=" + m[2]
+				} else {
+					arg = long
+				}
+			}
+		}
+
+		expanded = append(expanded, arg)
+	}
+
+	return expanded
+}
+
 // showHelp show a full help
 func showHelp() {
-	fmt.Println("Usage:")
+	fmt.Println(`Usage:
+  tracer [flags]
+  tracer traces search [flags]
+  tracer trace export <id...> [flags]
+  tracer trace diff <idA> <idB> [flags]
+  tracer graph [flags]
+  tracer logs stats [flags]
+  tracer labels [name...] [flags]
+  tracer completion bash|zsh|fish
+
+Flags:`)
 	pflag.PrintDefaults()
 	fmt.Printf(`
 
@@ -25,10 +76,42 @@ func showHelp() {
 
   ./tracer --since 1h --service squall --namespace /foo/bar
 
+> Display all queries for a service in a given namespace and its children from the last 1h
+
+  ./tracer --since 1h --service squall --namespace /foo/bar --recursive
+
 > Display all queries for a service in a given namespace that took more than 2s from the last 1h
 
   ./tracer --since 1h --service squall --namespace /foo/bar --slower-than 2s
 
+> Display the requests of the past hour that are unusual compared to the same hour 1 day and 7 days ago
+
+  ./tracer --since 1h --anomalies-only --baseline 1d --baseline 7d
+
+> Display all queries for a service with a given tag that took between 1s and 2s from the last 1h
+
+  ./tracer --since 1h --service squall --tag http.method=POST --slower-than 1s --faster-than 2s
+
+> List the last 20 traces of a service for a given span operation without going through the metrics
+
+  ./tracer traces search --since 1h --service squall --operation "retrieve-many processingunits" --limit 20
+
+> Export traces to the OTLP JSON format in a directory
+
+  ./tracer trace export 6a113d0efa9b259b 491cfcaef5a8e343 --format otlp --out ./traces
+
+> Compare the span trees of two traces
+
+  ./tracer trace diff 211c4e34e7b643ff 2db1a90e21745544
+
+> Display the calls between services sampled from the traces of the last hour
+
+  ./tracer graph --since 1h --service squall --sample 50
+
+> Render the jaeger dependency graph as graphviz
+
+  ./tracer graph --graph-source dependencies --graph-format dot | dot -Tsvg > graph.svg
+
 > Display all requests that returns with an error for the past hour
 
   ./tracer --since 1h --errors-only
@@ -41,22 +124,78 @@ func showHelp() {
 
   ./tracer --since 1h --url /flowreports
 
+> Display all processing units retrieve-many requests in the past hour
+
+  ./tracer --since 1h --identity processingunits --elemental-operation retrieve-many
+
 > Display all 400-403 requests on service squall, cid and /issue between two dates
 
   ./tracer --code 400-403 --service squal --service cid --url /issue --from 2020-10-21T17:56:17Z --to 2020-10-22T17:56:17Z
 
+> Display all queries for a service in the 10 minutes around an incident, in the Paris time zone
+
+  ./tracer --service squall --around "2020-10-22 19:56" --window 10m --tz Europe/Paris
+
+> Display the logs of a service from 2 hours ago to 30 minutes ago
+
+  ./tracer --log --service squall --from "2 hours ago" --to now-30m
+
 > Display logs for 2 services between two dates
 
   ./tracer --log --service squal --service cid --from 2020-10-21T17:56:17Z --to 2020-10-22T17:56:17Z
 
 > Display logs with a custom filter for a given service
 
-  ./tracer --log --service squall --log-filter '|~"ERROR"'
+  ./tracer --log --service squall --regex 'ERROR|WARNING'
 
 > Display logs with a custom filter without service
 
   ./tracer --log --log-filter '{type="aporeto",app!~"squall|wutai.*"}|~"ERROR|WARNING"'
 
+> Pipe the logs of a service to jq
+
+  ./tracer --log --service squall --log-output jsonl | jq .line
+
+> Display the warnings and errors of a service for a namespace
+
+  ./tracer --log --service squall --min-level warn --field ns=/foo/bar
+
+> Display the lines of a pod containing a text but not another one, and print the generated query
+
+  ./tracer --log --pod squall-5d9f7c-x2x7z --contains "unable to" --exclude health --show-query
+
+> Group the error logs of a service over the last hour into patterns
+
+  ./tracer --patterns --service squall --since 1h --min-level error
+
+> Display the panics of a service with the 20 lines before them in the same pod
+
+  ./tracer --log --service squall --log-filter '|~"panic"' -B 20
+
+> Save the logs of every pod of a service during an incident, one compressed file per pod
+
+  ./tracer --log-dir ./incident --service squall --from 2023-11-14T22:00:00Z --to 2023-11-14T23:00:00Z --gzip --lines 5000
+
+> Display the top services by error lines over the last 6 hours
+
+  ./tracer logs stats --since 6h --step 10m
+
+> Display the rate of lines per pod of a service
+
+  ./tracer logs stats --stats-query 'sum by (pod) (rate({app="squall"}[5m]))' --step 5m
+
+> List the label names known by the metrics and the logs over the last day
+
+  ./tracer labels --since 1d
+
+> List the services and pods seen in the last hour
+
+  ./tracer labels service app pod --since 1h
+
+> Enable the shell completion of the flags, stacks and services in bash
+
+  source <(./tracer completion bash)
+
 Some queries are not providing traces (like reports because this is too much for jaeger to handle).
 In general errors are logged in the service in debug mode. Use the switch-debug <service name>  command to enable it.
 And look at the logs either through Grafana->Explore->Loki or with the k get log <pod_name> command.
@@ -0,0 +1,44 @@
+package configuration
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandShorthands(t *testing.T) {
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			"shorthands",
+			[]string{"--log", "-A", "3", "-B5", "-C"},
+			[]string{"--log", "--after-context", "3", "--before-context=5", "--context"},
+		},
+		{
+			"values starting like a shorthand",
+			[]string{"--contains", "-Bad request", "--exclude", "-A=3", "-Cx"},
+			[]string{"--contains", "-Bad request", "--exclude", "-A=3", "-Cx"},
+		},
+		{
+			"unknown shorthand",
+			[]string{"-X", "-h"},
+			[]string{"-X", "-h"},
+		},
+		{
+			"arguments",
+			[]string{"-A2", "--", "-B3"},
+			[]string{"--after-context=2", "--", "-B3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandShorthands(tt.args); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandShorthands() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
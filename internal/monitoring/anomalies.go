@@ -0,0 +1,99 @@
+package monitoring
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// anomalyThreshold is the z-score above which a count is flagged
+const anomalyThreshold = 3.0
+
+// Anomaly represents the comparison of an APIError count
+// with the counts of the same window in the past
+type Anomaly struct {
+	Baselines []int
+	Mean      float64
+	Score     float64
+	Flagged   bool
+}
+
+// String returns a short representation of the anomaly score
+func (a *Anomaly) String() string {
+
+	if a == nil {
+		return ""
+	}
+
+	if a.Flagged {
+		return fmt.Sprintf("%.1f (!)", a.Score)
+	}
+
+	return fmt.Sprintf("%.1f", a.Score)
+}
+
+// ScoreAnomalies compares the count of each APIError with the same window at the given
+// offsets in the past and sets its Anomaly. The results are modified in place.
+func (m Client) ScoreAnomalies(proxy int, since time.Duration, at time.Time, namespace string, recursive bool, offsets []time.Duration, results APIErrors) error {
+
+	baselines := make([]map[string]int, len(offsets))
+
+	for i, offset := range offsets {
+
+		res, err := m.getAPIErrors(proxy, since, offset, at, namespace, recursive)
+		if err != nil {
+			return fmt.Errorf("unable to query baseline at offset %s: %w", offset, err)
+		}
+
+		baselines[i] = make(map[string]int)
+		for _, r := range mergeAPIErrors(res) {
+			baselines[i][r.key()] = r.Count
+		}
+
+		zap.L().Debug("Baseline retrieved", zap.Duration("offset", offset), zap.Int("results", len(res)))
+	}
+
+	for i := range results {
+
+		counts := make([]int, len(baselines))
+		for j, b := range baselines {
+			counts[j] = b[results[i].key()]
+		}
+
+		results[i].Anomaly = scoreAnomaly(results[i].Count, counts)
+	}
+
+	return nil
+}
+
+// scoreAnomaly computes the z-score of the count against the baselines.
+// As counts behave like a Poisson process, the deviation is at least the
+// square root of the mean so small counts are not flagged too easily.
+func scoreAnomaly(count int, baselines []int) *Anomaly {
+
+	a := &Anomaly{Baselines: baselines}
+
+	if len(baselines) == 0 {
+		return a
+	}
+
+	for _, b := range baselines {
+		a.Mean += float64(b)
+	}
+	a.Mean /= float64(len(baselines))
+
+	variance := 0.0
+	for _, b := range baselines {
+		variance += math.Pow(float64(b)-a.Mean, 2)
+	}
+	variance /= float64(len(baselines))
+
+	deviation := math.Max(math.Sqrt(variance), math.Max(math.Sqrt(a.Mean), 1))
+
+	a.Score = (float64(count) - a.Mean) / deviation
+	a.Flagged = math.Abs(a.Score) >= anomalyThreshold
+
+	return a
+}
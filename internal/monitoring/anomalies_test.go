@@ -0,0 +1,83 @@
+package monitoring
+
+import (
+	"testing"
+)
+
+func TestScoreAnomaly(t *testing.T) {
+	type args struct {
+		count     int
+		baselines []int
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantMean    float64
+		wantScore   float64
+		wantFlagged bool
+	}{
+		{
+			"no baselines",
+			args{
+				count: 30,
+			},
+			0,
+			0,
+			false,
+		},
+		{
+			"usual count",
+			args{
+				count:     30,
+				baselines: []int{28, 32},
+			},
+			30,
+			0,
+			false,
+		},
+		{
+			"new errors",
+			args{
+				count:     30,
+				baselines: []int{0, 0},
+			},
+			0,
+			30,
+			true,
+		},
+		{
+			"small deviation on small counts",
+			args{
+				count:     3,
+				baselines: []int{1, 1},
+			},
+			1,
+			2,
+			false,
+		},
+		{
+			"spike",
+			args{
+				count:     400,
+				baselines: []int{90, 110},
+			},
+			100,
+			30,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scoreAnomaly(tt.args.count, tt.args.baselines)
+			if got.Mean != tt.wantMean {
+				t.Errorf("scoreAnomaly() Mean = %v, want %v", got.Mean, tt.wantMean)
+			}
+			if got.Score != tt.wantScore {
+				t.Errorf("scoreAnomaly() Score = %v, want %v", got.Score, tt.wantScore)
+			}
+			if got.Flagged != tt.wantFlagged {
+				t.Errorf("scoreAnomaly() Flagged = %v, want %v", got.Flagged, tt.wantFlagged)
+			}
+		})
+	}
+}
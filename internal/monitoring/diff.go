@@ -0,0 +1,153 @@
+package monitoring
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TraceDiff is the structural difference between two traces
+type TraceDiff struct {
+	A     string
+	B     string
+	Spans []SpanDiff
+}
+
+// SpanDiff is the difference between two spans aligned on their
+// service and operation path. A or B is nil if the span is only
+// present in one of the traces.
+type SpanDiff struct {
+	Path          string
+	A             *Span
+	B             *Span
+	DurationDelta time.Duration
+	Tags          []TagChange
+}
+
+// Delta returns the signed duration delta, empty if the
+// span is only present in one of the traces
+func (s SpanDiff) Delta() string {
+
+	switch {
+	case s.A == nil || s.B == nil:
+		return ""
+	case s.DurationDelta > 0:
+		return "+" + s.DurationDelta.String()
+	default:
+		return s.DurationDelta.String()
+	}
+}
+
+// TagChange is a tag with a different value between two spans,
+// the value is empty if the tag is not set.
+type TagChange struct {
+	Key string
+	A   string
+	B   string
+}
+
+// DiffTraces aligns the span trees of the two traces by service and
+// operation path and returns their differences.
+func DiffTraces(a, b Trace) TraceDiff {
+
+	keysA, spansA := alignSpans(a)
+	keysB, spansB := alignSpans(b)
+
+	d := TraceDiff{A: a.TraceID, B: b.TraceID}
+
+	for _, key := range keysA {
+
+		sd := SpanDiff{Path: key, A: spansA[key], B: spansB[key]}
+
+		if sd.B != nil {
+			sd.DurationDelta = sd.B.Elapsed() - sd.A.Elapsed()
+			sd.Tags = diffTags(sd.A.Tags, sd.B.Tags)
+		}
+
+		d.Spans = append(d.Spans, sd)
+	}
+
+	for _, key := range keysB {
+		if _, ok := spansA[key]; !ok {
+			d.Spans = append(d.Spans, SpanDiff{Path: key, B: spansB[key]})
+		}
+	}
+
+	return d
+}
+
+// alignSpans returns the spans of the trace indexed by their path ordered
+// by start time. The path is made of the service and operation of the span
+// and its ancestors and is suffixed by the occurrence number if repeated.
+func alignSpans(t Trace) ([]string, map[string]*Span) {
+
+	byID := make(map[string]*Span, len(t.Spans))
+	for i := range t.Spans {
+		byID[t.Spans[i].SpanID] = &t.Spans[i]
+	}
+
+	ordered := make([]*Span, len(t.Spans))
+	for i := range t.Spans {
+		ordered[i] = &t.Spans[i]
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].StartTime < ordered[j].StartTime })
+
+	keys := make([]string, 0, len(ordered))
+	spans := make(map[string]*Span, len(ordered))
+	occurrences := make(map[string]int)
+
+	for _, s := range ordered {
+
+		parts := []string{}
+		for current, depth := s, 0; current != nil && depth <= len(t.Spans); depth++ {
+			parts = append([]string{t.Service(*current) + ":" + current.OperationName}, parts...)
+			current = byID[current.ParentID()]
+		}
+
+		path := strings.Join(parts, " > ")
+		occurrences[path]++
+		if n := occurrences[path]; n > 1 {
+			path = fmt.Sprintf("%s #%d", path, n)
+		}
+
+		keys = append(keys, path)
+		spans[path] = s
+	}
+
+	return keys, spans
+}
+
+// diffTags returns the tags that changed between the two sets of tags
+func diffTags(a, b []KeyValue) []TagChange {
+
+	valuesA := make(map[string]string, len(a))
+	for _, t := range a {
+		valuesA[t.Key] = tagString(t)
+	}
+
+	valuesB := make(map[string]string, len(b))
+	for _, t := range b {
+		valuesB[t.Key] = tagString(t)
+	}
+
+	keys := []string{}
+	for k := range valuesA {
+		keys = append(keys, k)
+	}
+	for k := range valuesB {
+		if _, ok := valuesA[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := []TagChange{}
+	for _, k := range keys {
+		if valuesA[k] != valuesB[k] {
+			changes = append(changes, TagChange{Key: k, A: valuesA[k], B: valuesB[k]})
+		}
+	}
+
+	return changes
+}
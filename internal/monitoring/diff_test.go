@@ -0,0 +1,105 @@
+package monitoring
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffTraces(t *testing.T) {
+
+	processes := map[string]Process{
+		"p1": {ServiceName: "midgard"},
+		"p2": {ServiceName: "cid"},
+	}
+
+	child := func(id, parent, operation, process string, start, duration int64, tags ...KeyValue) Span {
+		s := Span{SpanID: id, OperationName: operation, ProcessID: process, StartTime: start, Duration: duration, Tags: tags}
+		if parent != "" {
+			s.References = []Reference{{RefType: "CHILD_OF", SpanID: parent}}
+		}
+		return s
+	}
+
+	a := Trace{
+		TraceID:   "a",
+		Processes: processes,
+		Spans: []Span{
+			child("1", "", "create issue", "p1", 0, 200000, KeyValue{Key: "status.code", Value: float64(200)}),
+			child("2", "1", "mongo", "p1", 10, 1000),
+			child("3", "1", "mongo", "p1", 20, 1000),
+			child("4", "1", "create authz", "p2", 30, 5000),
+		},
+	}
+
+	b := Trace{
+		TraceID:   "b",
+		Processes: processes,
+		Spans: []Span{
+			child("1", "", "create issue", "p1", 0, 4000000, KeyValue{Key: "status.code", Value: float64(500)}, KeyValue{Key: "error", Value: true}),
+			child("2", "1", "mongo", "p1", 10, 3000000),
+			child("5", "1", "retry", "p1", 40, 1000),
+		},
+	}
+
+	got := DiffTraces(a, b)
+
+	if got.A != "a" || got.B != "b" {
+		t.Errorf("DiffTraces() ids = %s %s", got.A, got.B)
+	}
+
+	wantPaths := []string{
+		"midgard:create issue",
+		"midgard:create issue > midgard:mongo",
+		"midgard:create issue > midgard:mongo #2",
+		"midgard:create issue > cid:create authz",
+		"midgard:create issue > midgard:retry",
+	}
+	gotPaths := []string{}
+	for _, s := range got.Spans {
+		gotPaths = append(gotPaths, s.Path)
+	}
+	if !reflect.DeepEqual(gotPaths, wantPaths) {
+		t.Fatalf("DiffTraces() paths = %v, want %v", gotPaths, wantPaths)
+	}
+
+	if d := got.Spans[0].DurationDelta; d != 3800*time.Millisecond {
+		t.Errorf("DiffTraces() root delta = %v", d)
+	}
+
+	wantTags := []TagChange{
+		{Key: "error", A: "", B: "true"},
+		{Key: "status.code", A: "200", B: "500"},
+	}
+	if !reflect.DeepEqual(got.Spans[0].Tags, wantTags) {
+		t.Errorf("DiffTraces() root tags = %v, want %v", got.Spans[0].Tags, wantTags)
+	}
+
+	if got.Spans[2].B != nil || got.Spans[3].B != nil {
+		t.Errorf("DiffTraces() expected spans only in a")
+	}
+
+	if got.Spans[4].A != nil || got.Spans[4].B == nil {
+		t.Errorf("DiffTraces() expected span only in b")
+	}
+}
+
+func TestSpanDiff_Delta(t *testing.T) {
+	tests := []struct {
+		name string
+		diff SpanDiff
+		want string
+	}{
+		{"slower", SpanDiff{A: &Span{}, B: &Span{}, DurationDelta: 12 * time.Millisecond}, "+12ms"},
+		{"faster", SpanDiff{A: &Span{}, B: &Span{}, DurationDelta: -12 * time.Millisecond}, "-12ms"},
+		{"same", SpanDiff{A: &Span{}, B: &Span{}}, "0s"},
+		{"only in a", SpanDiff{A: &Span{}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.diff.Delta(); got != tt.want {
+				t.Errorf("SpanDiff.Delta() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
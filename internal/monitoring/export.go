@@ -0,0 +1,283 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ExportFormatJaeger is the jaeger JSON format as returned by its API
+	ExportFormatJaeger = "jaeger"
+
+	// ExportFormatOTLP is the OpenTelemetry OTLP JSON format
+	ExportFormatOTLP = "otlp"
+
+	// ExportFormatZipkin is the zipkin v2 JSON format
+	ExportFormatZipkin = "zipkin"
+)
+
+// otlpSpanKindUnspecified is the OTLP SPAN_KIND_UNSPECIFIED kind of
+// the spans without span.kind tag or with an unknown one
+const otlpSpanKindUnspecified = 0
+
+// otlpSpanKinds maps the jaeger span.kind tag to the OTLP span kinds
+var otlpSpanKinds = map[string]int{
+	"internal": 1,
+	"server":   2,
+	"client":   3,
+	"producer": 4,
+	"consumer": 5,
+}
+
+// zipkinSpanKinds maps the jaeger span.kind tag to the zipkin span kinds,
+// the internal spans have no kind in zipkin
+var zipkinSpanKinds = map[string]string{
+	"server":   "SERVER",
+	"client":   "CLIENT",
+	"producer": "PRODUCER",
+	"consumer": "CONSUMER",
+}
+
+// traceIDRegexp matches the hexadecimal trace IDs
+var traceIDRegexp = regexp.MustCompile(`^[0-9a-fA-F]{1,32}$`)
+
+// CheckTraceID returns an error if the id is not an hexadecimal trace ID,
+// it must be checked before using an id from the user in a path
+func CheckTraceID(id string) error {
+
+	if !traceIDRegexp.MatchString(id) {
+		return fmt.Errorf("invalid trace id %q: must be up to 32 hexadecimal characters", id)
+	}
+
+	return nil
+}
+
+// zipkinSpan is a zipkin v2 span
+type zipkinSpan struct {
+	TraceID       string             `json:"traceId"`
+	ID            string             `json:"id"`
+	ParentID      string             `json:"parentId,omitempty"`
+	Name          string             `json:"name"`
+	Kind          string             `json:"kind,omitempty"`
+	Timestamp     int64              `json:"timestamp"`
+	Duration      int64              `json:"duration"`
+	LocalEndpoint zipkinEndpoint     `json:"localEndpoint"`
+	Tags          map[string]string  `json:"tags,omitempty"`
+	Annotations   []zipkinAnnotation `json:"annotations,omitempty"`
+}
+
+// zipkinEndpoint is the endpoint emitting a zipkin span
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+}
+
+// zipkinAnnotation is an event that happened during a zipkin span
+type zipkinAnnotation struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// ExportTrace converts the trace to the given format
+func ExportTrace(t Trace, format string) ([]byte, error) {
+
+	switch format {
+	case ExportFormatJaeger:
+		return json.MarshalIndent(traceResult{Data: []Trace{t}}, "", "  ")
+	case ExportFormatOTLP:
+		return json.MarshalIndent(toOTLP(t), "", "  ")
+	case ExportFormatZipkin:
+		return json.MarshalIndent(toZipkin(t), "", "  ")
+	default:
+		return nil, fmt.Errorf("unknown export format: %s", format)
+	}
+}
+
+// toOTLP converts the trace to the OTLP model
+func toOTLP(t Trace) otlpTraces {
+
+	processIDs := make([]string, 0, len(t.Processes))
+	for id := range t.Processes {
+		processIDs = append(processIDs, id)
+	}
+	sort.Strings(processIDs)
+
+	o := otlpTraces{}
+
+	for _, id := range processIDs {
+
+		p := t.Processes[id]
+		rs := otlpResourceSpans{
+			Resource: otlpResource{
+				Attributes: append([]otlpKeyValue{{Key: "service.name", Value: otlpValue(KeyValue{Type: "string", Value: p.ServiceName})}}, otlpAttributes(p.Tags)...),
+			},
+		}
+
+		ss := otlpScopeSpans{}
+		for _, s := range t.Spans {
+
+			if s.ProcessID != id {
+				continue
+			}
+
+			span := otlpSpan{
+				TraceID:           padID(s.TraceID, 32),
+				SpanID:            padID(s.SpanID, 16),
+				Name:              s.OperationName,
+				StartTimeUnixNano: otlpUint64(s.StartTime * 1000),
+				EndTimeUnixNano:   otlpUint64((s.StartTime + s.Duration) * 1000),
+				Kind:              otlpSpanKindUnspecified,
+			}
+
+			if parent := s.ParentID(); parent != "" {
+				span.ParentSpanID = padID(parent, 16)
+			}
+
+			for _, tag := range s.Tags {
+				switch {
+				case tag.Key == "span.kind":
+					if kind, ok := otlpSpanKinds[tagString(tag)]; ok {
+						span.Kind = kind
+					}
+				case tag.Key == "error" && tagString(tag) == "true":
+					span.Status = &otlpStatus{Code: otlpStatusError}
+				}
+			}
+
+			span.Attributes = otlpAttributes(s.Tags)
+
+			for _, l := range s.Logs {
+				event := otlpEvent{TimeUnixNano: otlpUint64(l.Timestamp * 1000), Name: "log"}
+				for _, f := range l.Fields {
+					if f.Key == "event" {
+						event.Name = tagString(f)
+						continue
+					}
+					event.Attributes = append(event.Attributes, otlpKeyValue{Key: f.Key, Value: otlpValue(f)})
+				}
+				span.Events = append(span.Events, event)
+			}
+
+			ss.Spans = append(ss.Spans, span)
+		}
+
+		rs.ScopeSpans = []otlpScopeSpans{ss}
+		o.ResourceSpans = append(o.ResourceSpans, rs)
+	}
+
+	return o
+}
+
+// otlpAttributes converts the jaeger tags to OTLP attributes
+func otlpAttributes(tags []KeyValue) []otlpKeyValue {
+
+	attributes := []otlpKeyValue{}
+	for _, tag := range tags {
+		if tag.Key == "span.kind" {
+			continue
+		}
+		attributes = append(attributes, otlpKeyValue{Key: tag.Key, Value: otlpValue(tag)})
+	}
+
+	return attributes
+}
+
+// otlpValue converts a jaeger tag value to an OTLP value
+func otlpValue(kv KeyValue) otlpAnyValue {
+
+	switch v := kv.Value.(type) {
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case float64:
+		if kv.Type == "int64" {
+			i := otlpUint64(v)
+			return otlpAnyValue{IntValue: &i}
+		}
+		return otlpAnyValue{DoubleValue: &v}
+	case int64:
+		i := otlpUint64(v)
+		return otlpAnyValue{IntValue: &i}
+	}
+
+	s := tagString(kv)
+	return otlpAnyValue{StringValue: &s}
+}
+
+// toZipkin converts the trace to the zipkin model
+func toZipkin(t Trace) []zipkinSpan {
+
+	spans := make([]zipkinSpan, 0, len(t.Spans))
+
+	for _, s := range t.Spans {
+
+		span := zipkinSpan{
+			TraceID:       zipkinTraceID(s.TraceID),
+			ID:            padID(s.SpanID, 16),
+			Name:          s.OperationName,
+			Timestamp:     s.StartTime,
+			Duration:      s.Duration,
+			LocalEndpoint: zipkinEndpoint{ServiceName: t.Service(s)},
+			Tags:          map[string]string{},
+		}
+
+		if parent := s.ParentID(); parent != "" {
+			span.ParentID = padID(parent, 16)
+		}
+
+		for _, tag := range s.Tags {
+			if tag.Key == "span.kind" {
+				span.Kind = zipkinSpanKinds[tagString(tag)]
+				continue
+			}
+			span.Tags[tag.Key] = tagString(tag)
+		}
+
+		for _, l := range s.Logs {
+			fields := make([]string, len(l.Fields))
+			for i, f := range l.Fields {
+				fields[i] = f.Key + "=" + tagString(f)
+			}
+			span.Annotations = append(span.Annotations, zipkinAnnotation{Timestamp: l.Timestamp, Value: strings.Join(fields, " ")})
+		}
+
+		spans = append(spans, span)
+	}
+
+	return spans
+}
+
+// tagString returns the string representation of a tag value
+func tagString(kv KeyValue) string {
+
+	switch v := kv.Value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	return fmt.Sprint(kv.Value)
+}
+
+// zipkinTraceID pads a trace ID to the 16 or 32 hexadecimal characters of zipkin
+func zipkinTraceID(id string) string {
+
+	if len(id) <= 16 {
+		return padID(id, 16)
+	}
+
+	return padID(id, 32)
+}
+
+// padID left pads an hexadecimal ID with zeros to the given length
+func padID(id string, length int) string {
+
+	if len(id) >= length {
+		return id
+	}
+
+	return strings.Repeat("0", length-len(id)) + id
+}
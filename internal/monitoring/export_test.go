@@ -0,0 +1,174 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestExportTrace(t *testing.T) {
+
+	trace := Trace{
+		TraceID: "6a113d0efa9b259b",
+		Processes: map[string]Process{
+			"p1": {ServiceName: "squall"},
+		},
+		Spans: []Span{
+			{
+				TraceID:       "6a113d0efa9b259b",
+				SpanID:        "6a113d0efa9b259b",
+				OperationName: "retrieve-many processingunits",
+				StartTime:     1600000000000000,
+				Duration:      200000,
+				ProcessID:     "p1",
+				Tags: []KeyValue{
+					{Key: "span.kind", Type: "string", Value: "server"},
+					{Key: "status.code", Type: "int64", Value: float64(403)},
+					{Key: "error", Type: "bool", Value: true},
+				},
+			},
+			{
+				TraceID:       "6a113d0efa9b259b",
+				SpanID:        "491ccfcaf5a8e343",
+				OperationName: "mongo",
+				StartTime:     1600000000100000,
+				Duration:      50000,
+				ProcessID:     "p1",
+				References:    []Reference{{RefType: "CHILD_OF", TraceID: "6a113d0efa9b259b", SpanID: "6a113d0efa9b259b"}},
+				Logs: []Log{
+					{
+						Timestamp: 1600000000120000,
+						Fields: []KeyValue{
+							{Key: "event", Type: "string", Value: "query"},
+							{Key: "slow", Type: "bool", Value: true},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("unknown format", func(t *testing.T) {
+		if _, err := ExportTrace(trace, "chien"); err == nil {
+			t.Errorf("ExportTrace() expected an error")
+		}
+	})
+
+	t.Run("otlp", func(t *testing.T) {
+
+		data, err := ExportTrace(trace, ExportFormatOTLP)
+		if err != nil {
+			t.Fatalf("ExportTrace() error = %v", err)
+		}
+
+		o := otlpTraces{}
+		if err := json.Unmarshal(data, &o); err != nil {
+			t.Fatalf("unable to decode otlp trace: %s", err)
+		}
+
+		got := o.toTrace()
+		if got.TraceID != "00000000000000006a113d0efa9b259b" {
+			t.Errorf("toTrace() TraceID = %v", got.TraceID)
+		}
+		if len(got.Spans) != 2 || got.Spans[1].ParentID() != "6a113d0efa9b259b" {
+			t.Fatalf("toTrace() Spans = %+v", got.Spans)
+		}
+		if want := []KeyValue{{Key: "status.code", Type: "int64", Value: int64(403)}, {Key: "error", Type: "bool", Value: true}}; !reflect.DeepEqual(got.Spans[0].Tags, want) {
+			t.Errorf("toTrace() Tags = %+v, want %+v", got.Spans[0].Tags, want)
+		}
+		if o.ResourceSpans[0].ScopeSpans[0].Spans[0].Kind != float64(2) {
+			t.Errorf("toOTLP() Kind = %v", o.ResourceSpans[0].ScopeSpans[0].Spans[0].Kind)
+		}
+		// The span without span.kind is explicitly unspecified
+		if o.ResourceSpans[0].ScopeSpans[0].Spans[1].Kind != float64(otlpSpanKindUnspecified) {
+			t.Errorf("toOTLP() Kind = %v, want SPAN_KIND_UNSPECIFIED", o.ResourceSpans[0].ScopeSpans[0].Spans[1].Kind)
+		}
+	})
+
+	t.Run("otlp unknown span kind", func(t *testing.T) {
+
+		unknown := Trace{
+			TraceID:   "1",
+			Processes: map[string]Process{"p1": {ServiceName: "squall"}},
+			Spans: []Span{{
+				TraceID:   "1",
+				SpanID:    "2",
+				ProcessID: "p1",
+				Tags:      []KeyValue{{Key: "span.kind", Type: "string", Value: "chien"}},
+			}},
+		}
+
+		if kind := toOTLP(unknown).ResourceSpans[0].ScopeSpans[0].Spans[0].Kind; kind != otlpSpanKindUnspecified {
+			t.Errorf("toOTLP() Kind = %v, want SPAN_KIND_UNSPECIFIED", kind)
+		}
+
+		span := toZipkin(unknown)[0]
+		if span.Kind != "" {
+			t.Errorf("toZipkin() Kind = %v, want none", span.Kind)
+		}
+		if span.TraceID != "0000000000000001" || span.ID != "0000000000000002" {
+			t.Errorf("toZipkin() ids = %s %s, want them padded to 16 characters", span.TraceID, span.ID)
+		}
+	})
+
+	t.Run("zipkin", func(t *testing.T) {
+
+		data, err := ExportTrace(trace, ExportFormatZipkin)
+		if err != nil {
+			t.Fatalf("ExportTrace() error = %v", err)
+		}
+
+		got := []zipkinSpan{}
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("unable to decode zipkin trace: %s", err)
+		}
+
+		want := []zipkinSpan{
+			{
+				TraceID:       "6a113d0efa9b259b",
+				ID:            "6a113d0efa9b259b",
+				Name:          "retrieve-many processingunits",
+				Kind:          "SERVER",
+				Timestamp:     1600000000000000,
+				Duration:      200000,
+				LocalEndpoint: zipkinEndpoint{ServiceName: "squall"},
+				Tags:          map[string]string{"status.code": "403", "error": "true"},
+			},
+			{
+				TraceID:       "6a113d0efa9b259b",
+				ID:            "491ccfcaf5a8e343",
+				ParentID:      "6a113d0efa9b259b",
+				Name:          "mongo",
+				Timestamp:     1600000000100000,
+				Duration:      50000,
+				LocalEndpoint: zipkinEndpoint{ServiceName: "squall"},
+				Annotations:   []zipkinAnnotation{{Timestamp: 1600000000120000, Value: "event=query slow=true"}},
+			},
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("toZipkin() = %+v, want %+v", got, want)
+		}
+	})
+}
+
+func TestCheckTraceID(t *testing.T) {
+	tests := []struct {
+		id      string
+		wantErr bool
+	}{
+		{"6a113d0efa9b259b", false},
+		{"00000000000000006A113D0EFA9B259B", false},
+		{"../../x", true},
+		{"6a113d0e/fa9b259b", true},
+		{"", true},
+		{"000000000000000000000000000000006a113d0efa9b259b", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if err := CheckTraceID(tt.id); (err != nil) != tt.wantErr {
+				t.Errorf("CheckTraceID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package monitoring
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Edge is the calls from a caller service to a callee service
+type Edge struct {
+	Caller   string
+	Callee   string
+	Calls    int
+	Errors   int
+	Duration time.Duration
+}
+
+// AvgLatency returns the average latency of the calls
+func (e Edge) AvgLatency() time.Duration {
+
+	if e.Calls == 0 {
+		return 0
+	}
+
+	return e.Duration / time.Duration(e.Calls)
+}
+
+// Graph is a service dependency graph
+type Graph struct {
+	Edges []Edge
+
+	// CallsOnly is set when only the call counts are known
+	CallsOnly bool
+}
+
+// DependencyBackend is a TraceBackend able to compute the service dependencies
+type DependencyBackend interface {
+
+	// Dependencies returns the edges of the service dependency graph. Only the
+	// call counts are available, errors and latencies are not computed.
+	Dependencies(end time.Time, lookback time.Duration) (Graph, error)
+}
+
+// BuildGraph builds the service dependency graph from the spans of the
+// traces. A call is a span whose parent is in a different service.
+func BuildGraph(traces []Trace) Graph {
+
+	edges := make(map[[2]string]*Edge)
+	seen := make(map[string]struct{}, len(traces))
+
+	for _, t := range traces {
+
+		// The same trace can be sampled several times
+		if _, ok := seen[t.TraceID]; ok {
+			continue
+		}
+		seen[t.TraceID] = struct{}{}
+
+		byID := make(map[string]Span, len(t.Spans))
+		for _, s := range t.Spans {
+			byID[s.SpanID] = s
+		}
+
+		for _, s := range t.Spans {
+
+			parent, ok := byID[s.ParentID()]
+			if !ok {
+				continue
+			}
+
+			caller, callee := t.Service(parent), t.Service(s)
+			if caller == callee {
+				continue
+			}
+
+			e, ok := edges[[2]string{caller, callee}]
+			if !ok {
+				e = &Edge{Caller: caller, Callee: callee}
+				edges[[2]string{caller, callee}] = e
+			}
+
+			e.Calls++
+			e.Duration += s.Elapsed()
+			if s.IsError() {
+				e.Errors++
+			}
+		}
+	}
+
+	g := Graph{}
+	for _, e := range edges {
+		g.Edges = append(g.Edges, *e)
+	}
+	g.sort()
+
+	return g
+}
+
+// Filter returns the graph restricted to the edges touching one of the services
+func (g Graph) Filter(services ...string) Graph {
+
+	if len(services) == 0 {
+		return g
+	}
+
+	keep := make(map[string]struct{}, len(services))
+	for _, s := range services {
+		keep[s] = struct{}{}
+	}
+
+	f := Graph{CallsOnly: g.CallsOnly}
+	for _, e := range g.Edges {
+		_, caller := keep[e.Caller]
+		_, callee := keep[e.Callee]
+		if caller || callee {
+			f.Edges = append(f.Edges, e)
+		}
+	}
+
+	return f
+}
+
+// DOT returns the graphviz representation of the graph
+func (g Graph) DOT() string {
+
+	b := &strings.Builder{}
+	b.WriteString("digraph tracer {\n")
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(b, "  %s -> %s [label=%s];\n", strconv.Quote(e.Caller), strconv.Quote(e.Callee), strconv.Quote(e.label(g.CallsOnly)))
+	}
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// mermaidID is the regexp of the characters not allowed in mermaid node IDs
+var mermaidID = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Mermaid returns the mermaid flowchart representation of the graph
+func (g Graph) Mermaid() string {
+
+	node := func(name string) string {
+		return fmt.Sprintf("%s[%s]", mermaidID.ReplaceAllString(name, "_"), mermaidQuote(name))
+	}
+
+	b := &strings.Builder{}
+	b.WriteString("flowchart LR\n")
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(b, "  %s -->|%s| %s\n", node(e.Caller), mermaidQuote(e.label(g.CallsOnly)), node(e.Callee))
+	}
+
+	return b.String()
+}
+
+// label returns the description of the edge
+func (e Edge) label(callsOnly bool) string {
+
+	if callsOnly {
+		return fmt.Sprintf("%d calls", e.Calls)
+	}
+
+	return fmt.Sprintf("%d calls, %d errors, avg %s", e.Calls, e.Errors, e.AvgLatency().Round(time.Millisecond))
+}
+
+// sort orders the edges by caller and callee
+func (g Graph) sort() {
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].Caller != g.Edges[j].Caller {
+			return g.Edges[i].Caller < g.Edges[j].Caller
+		}
+		return g.Edges[i].Callee < g.Edges[j].Callee
+	})
+}
+
+// mermaidQuote quotes a mermaid label, mermaid does not
+// support escaped quotes so they are replaced by their entity
+func mermaidQuote(label string) string {
+	return `"` + strings.ReplaceAll(label, `"`, "#quot;") + `"`
+}
@@ -0,0 +1,100 @@
+package monitoring
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildGraph(t *testing.T) {
+
+	trace := Trace{
+		TraceID: "a",
+		Processes: map[string]Process{
+			"p1": {ServiceName: "midgard"},
+			"p2": {ServiceName: "cid"},
+			"p3": {ServiceName: "squall"},
+		},
+		Spans: []Span{
+			{SpanID: "1", ProcessID: "p1", Duration: 300000},
+			{SpanID: "2", ProcessID: "p1", Duration: 1000, References: []Reference{{RefType: "CHILD_OF", SpanID: "1"}}},
+			{SpanID: "3", ProcessID: "p2", Duration: 10000, References: []Reference{{RefType: "CHILD_OF", SpanID: "1"}}},
+			{SpanID: "4", ProcessID: "p2", Duration: 30000, References: []Reference{{RefType: "CHILD_OF", SpanID: "2"}}, Tags: []KeyValue{{Key: "error", Value: true}}},
+			{SpanID: "5", ProcessID: "p3", Duration: 5000, References: []Reference{{RefType: "CHILD_OF", SpanID: "3"}}},
+		},
+	}
+
+	got := BuildGraph([]Trace{trace, trace})
+
+	want := Graph{
+		Edges: []Edge{
+			{Caller: "cid", Callee: "squall", Calls: 1, Duration: 5 * time.Millisecond},
+			{Caller: "midgard", Callee: "cid", Calls: 2, Errors: 1, Duration: 40 * time.Millisecond},
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("BuildGraph() = %+v, want %+v", got, want)
+	}
+
+	if l := got.Edges[1].AvgLatency(); l != 20*time.Millisecond {
+		t.Errorf("AvgLatency() = %v", l)
+	}
+
+	if f := got.Filter("squall"); len(f.Edges) != 1 || f.Edges[0].Caller != "cid" {
+		t.Errorf("Filter() = %+v", f)
+	}
+
+	wantDOT := `digraph tracer {
+  "cid" -> "squall" [label="1 calls, 0 errors, avg 5ms"];
+  "midgard" -> "cid" [label="2 calls, 1 errors, avg 20ms"];
+}
+`
+	if dot := got.DOT(); dot != wantDOT {
+		t.Errorf("DOT() = %v, want %v", dot, wantDOT)
+	}
+
+	wantMermaid := `flowchart LR
+  cid["cid"] -->|"1 calls, 0 errors, avg 5ms"| squall["squall"]
+  midgard["midgard"] -->|"2 calls, 1 errors, avg 20ms"| cid["cid"]
+`
+	if mermaid := got.Mermaid(); mermaid != wantMermaid {
+		t.Errorf("Mermaid() = %v, want %v", mermaid, wantMermaid)
+	}
+}
+
+func TestGraphCallsOnly(t *testing.T) {
+
+	g := Graph{
+		CallsOnly: true,
+		Edges: []Edge{
+			{Caller: "gaga", Callee: "squall", Calls: 42},
+			{Caller: "squall", Callee: "midgard", Calls: 3},
+		},
+	}
+
+	if f := g.Filter("gaga", "midgard"); len(f.Edges) != 2 || !f.CallsOnly {
+		t.Errorf("Filter() = %+v", f)
+	}
+
+	want := `digraph tracer {
+  "gaga" -> "squall" [label="42 calls"];
+  "squall" -> "midgard" [label="3 calls"];
+}
+`
+	if dot := g.DOT(); dot != want {
+		t.Errorf("DOT() = %v, want %v", dot, want)
+	}
+}
+
+func TestMermaidQuote(t *testing.T) {
+
+	g := Graph{CallsOnly: true, Edges: []Edge{{Caller: `squall "v2"`, Callee: "midgard", Calls: 1}}}
+
+	want := `flowchart LR
+  squall__v2_["squall #quot;v2#quot;"] -->|"1 calls"| midgard["midgard"]
+`
+	if mermaid := g.Mermaid(); mermaid != want {
+		t.Errorf("Mermaid() = %v, want %v", mermaid, want)
+	}
+}
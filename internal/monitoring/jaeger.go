@@ -0,0 +1,248 @@
+package monitoring
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/go-querystring/query"
+	"go.uber.org/zap"
+)
+
+// jaegerBackend is the jaeger implementation of the TraceBackend
+type jaegerBackend struct {
+	client Client
+	proxy  int
+}
+
+// traceResult is a result of a jaeger trace search
+type traceResult struct {
+	Data []Trace `json:"data"`
+}
+
+// servicesResult is the result of the jaeger services listing
+type servicesResult struct {
+	Data []string `json:"data"`
+}
+
+// dependenciesResult is the result of the jaeger dependencies listing
+type dependenciesResult struct {
+	Data []struct {
+		Parent    string `json:"parent"`
+		Child     string `json:"child"`
+		CallCount int    `json:"callCount"`
+	} `json:"data"`
+}
+
+// SearchTraces implements the TraceBackend interface
+func (j jaegerBackend) SearchTraces(params TracingQueryParameters) ([]Trace, error) {
+
+	q, err := query.Values(params)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse query parameters: %w", err)
+	}
+
+	p := &traceResult{}
+	if err := j.client.getJSON(j.proxy, "api/traces", q, p); err != nil {
+		return nil, fmt.Errorf("unable to get traces: %w", err)
+	}
+
+	zap.L().Debug("Query jaeger", zap.Reflect("params", params), zap.Int("results", len(p.Data)))
+
+	return p.Data, nil
+}
+
+// GetTrace implements the TraceBackend interface
+func (j jaegerBackend) GetTrace(id string) (Trace, error) {
+
+	p := &traceResult{}
+	if err := j.client.getJSON(j.proxy, "api/traces/"+url.PathEscape(id), nil, p); err != nil {
+		return Trace{}, fmt.Errorf("unable to get trace %s: %w", id, err)
+	}
+
+	if len(p.Data) == 0 {
+		return Trace{}, fmt.Errorf("unable to get trace %s: not found", id)
+	}
+
+	return p.Data[0], nil
+}
+
+// ListServices implements the TraceBackend interface
+func (j jaegerBackend) ListServices() ([]string, error) {
+
+	p := &servicesResult{}
+	if err := j.client.getJSON(j.proxy, "api/services", nil, p); err != nil {
+		return nil, fmt.Errorf("unable to get services: %w", err)
+	}
+
+	zap.L().Debug("Query jaeger services", zap.Strings("services", p.Data))
+
+	return p.Data, nil
+}
+
+// Trace is a jaeger trace
+type Trace struct {
+	TraceID   string             `json:"traceID"`
+	Spans     []Span             `json:"spans"`
+	Processes map[string]Process `json:"processes"`
+}
+
+// Span is a jaeger span, times are in microseconds
+type Span struct {
+	TraceID       string      `json:"traceID"`
+	SpanID        string      `json:"spanID"`
+	Flags         int         `json:"flags"`
+	OperationName string      `json:"operationName"`
+	References    []Reference `json:"references"`
+	StartTime     int64       `json:"startTime"`
+	Duration      int64       `json:"duration"`
+	Tags          []KeyValue  `json:"tags"`
+	Logs          []Log       `json:"logs"`
+	ProcessID     string      `json:"processID"`
+	Warnings      []string    `json:"warnings"`
+}
+
+// KeyValue is a typed tag or log field
+type KeyValue struct {
+	Key   string `json:"key"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+// Log is a set of fields logged in a span at a given time in microseconds
+type Log struct {
+	Timestamp int64      `json:"timestamp"`
+	Fields    []KeyValue `json:"fields"`
+}
+
+// Reference is a reference from a span to another
+type Reference struct {
+	RefType string `json:"refType"`
+	TraceID string `json:"traceID"`
+	SpanID  string `json:"spanID"`
+}
+
+// Process is the process emitting spans
+type Process struct {
+	ServiceName string     `json:"serviceName"`
+	Tags        []KeyValue `json:"tags"`
+}
+
+// Dependencies implements the DependencyBackend interface
+func (j jaegerBackend) Dependencies(end time.Time, lookback time.Duration) (Graph, error) {
+
+	q := url.Values{}
+	q.Set("endTs", strconv.FormatInt(end.UnixMilli(), 10))
+	q.Set("lookback", strconv.FormatInt(lookback.Milliseconds(), 10))
+
+	p := &dependenciesResult{}
+	if err := j.client.getJSON(j.proxy, "api/dependencies", q, p); err != nil {
+		return Graph{}, fmt.Errorf("unable to get dependencies: %w", err)
+	}
+
+	g := Graph{CallsOnly: true}
+	for _, d := range p.Data {
+		g.Edges = append(g.Edges, Edge{Caller: d.Parent, Callee: d.Child, Calls: d.CallCount})
+	}
+	g.sort()
+
+	return g, nil
+}
+
+// Root returns the root span of the trace, the one without parent in the trace
+func (t Trace) Root() *Span {
+
+	ids := make(map[string]struct{}, len(t.Spans))
+	for _, s := range t.Spans {
+		ids[s.SpanID] = struct{}{}
+	}
+
+	var root *Span
+	for i, s := range t.Spans {
+
+		if parent := s.ParentID(); parent != "" {
+			if _, ok := ids[parent]; ok {
+				continue
+			}
+		}
+
+		// In case of several roots keep the first one
+		if root == nil || s.StartTime < root.StartTime {
+			root = &t.Spans[i]
+		}
+	}
+
+	return root
+}
+
+// Start returns the start time of the trace
+func (t Trace) Start() time.Time {
+
+	if len(t.Spans) == 0 {
+		return time.Time{}
+	}
+
+	start := t.Spans[0].StartTime
+	for _, s := range t.Spans {
+		if s.StartTime < start {
+			start = s.StartTime
+		}
+	}
+
+	return time.UnixMicro(start)
+}
+
+// Duration returns the duration of the trace from the first span start to the last span end
+func (t Trace) Duration() time.Duration {
+
+	if len(t.Spans) == 0 {
+		return 0
+	}
+
+	start, end := t.Spans[0].StartTime, t.Spans[0].StartTime+t.Spans[0].Duration
+	for _, s := range t.Spans {
+		if s.StartTime < start {
+			start = s.StartTime
+		}
+		if s.StartTime+s.Duration > end {
+			end = s.StartTime + s.Duration
+		}
+	}
+
+	return time.Duration(end-start) * time.Microsecond
+}
+
+// Service returns the service name of the span in the trace
+func (t Trace) Service(s Span) string {
+	return t.Processes[s.ProcessID].ServiceName
+}
+
+// ParentID returns the ID of the parent span if any
+func (s Span) ParentID() string {
+
+	for _, r := range s.References {
+		if r.RefType == "CHILD_OF" {
+			return r.SpanID
+		}
+	}
+
+	return ""
+}
+
+// IsError returns true if the span is tagged in error
+func (s Span) IsError() bool {
+
+	for _, t := range s.Tags {
+		if t.Key == "error" && tagString(t) == "true" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Elapsed returns the duration of the span
+func (s Span) Elapsed() time.Duration {
+	return time.Duration(s.Duration) * time.Microsecond
+}
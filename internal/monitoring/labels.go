@@ -0,0 +1,107 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// lokiLabelsResponse is the response of the loki labels endpoints
+type lokiLabelsResponse struct {
+	Data []string `json:"data"`
+}
+
+// MetricLabelNames returns the label names of the metrics in the window
+func (m Client) MetricLabelNames(proxy int, from, to time.Time) ([]string, error) {
+
+	v1api, err := m.promAPI(proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	names, warnings, err := v1api.LabelNames(ctx, nil, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get metric label names: %w", err)
+	}
+
+	if len(warnings) > 0 {
+		zap.L().Warn("Warning while querying Prometheus", zap.Strings("warnings", warnings))
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// MetricLabelValues returns the values of a label of the metrics in the window
+func (m Client) MetricLabelValues(proxy int, label string, from, to time.Time) ([]string, error) {
+
+	v1api, err := m.promAPI(proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	res, warnings, err := v1api.LabelValues(ctx, label, nil, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get metric label values: %w", err)
+	}
+
+	if len(warnings) > 0 {
+		zap.L().Warn("Warning while querying Prometheus", zap.Strings("warnings", warnings))
+	}
+
+	values := make([]string, len(res))
+	for i, v := range res {
+		values[i] = string(v)
+	}
+	sort.Strings(values)
+
+	return values, nil
+}
+
+// LabelNames returns the label names of the log streams in the window
+func (l LokiClient) LabelNames(from, to time.Time) ([]string, error) {
+
+	res := &lokiLabelsResponse{}
+	if err := l.client.getJSON(l.proxy, "loki/api/v1/labels", windowValues(from, to), res); err != nil {
+		return nil, fmt.Errorf("unable to get log label names: %w", err)
+	}
+
+	sort.Strings(res.Data)
+
+	return res.Data, nil
+}
+
+// LabelValues returns the values of a label of the log streams in the window
+func (l LokiClient) LabelValues(label string, from, to time.Time) ([]string, error) {
+
+	res := &lokiLabelsResponse{}
+	if err := l.client.getJSON(l.proxy, fmt.Sprintf("loki/api/v1/label/%s/values", url.PathEscape(label)), windowValues(from, to), res); err != nil {
+		return nil, fmt.Errorf("unable to get log label values: %w", err)
+	}
+
+	sort.Strings(res.Data)
+
+	return res.Data, nil
+}
+
+// windowValues returns the loki query parameters of a time window
+func windowValues(from, to time.Time) url.Values {
+
+	values := url.Values{}
+	values.Set("start", strconv.FormatInt(from.UnixNano(), 10))
+	values.Set("end", strconv.FormatInt(to.UnixNano(), 10))
+
+	return values
+}
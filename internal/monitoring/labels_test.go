@@ -0,0 +1,75 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLabels(t *testing.T) {
+
+	from, to := time.Unix(1700000000, 0), time.Unix(1700003600, 0)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		var data []string
+
+		switch r.URL.Path {
+
+		case "/api/datasources/proxy/1/api/v1/labels":
+			data = []string{"service", "code", "__name__"}
+
+		case "/api/datasources/proxy/1/api/v1/label/service/values":
+			data = []string{"squall", "cid"}
+
+		case "/api/datasources/proxy/2/loki/api/v1/labels":
+			if r.URL.Query().Get("start") != "1700000000000000000" || r.URL.Query().Get("end") != "1700003600000000000" {
+				t.Errorf("window = %v", r.URL.Query())
+			}
+			data = []string{"pod", "app"}
+
+		case "/api/datasources/proxy/2/loki/api/v1/label/app/values":
+			data = []string{"squall", "midgard"}
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{"status": "success", "data": data}) // nolint
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	m := Client{url: u, client: http.Client{Transport: http.DefaultTransport}, limiters: map[int]*rateLimiter{}}
+
+	check := func(name string, got []string, err error, want []string) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s() error = %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s() = %v, want %v", name, got, want)
+		}
+	}
+
+	names, err := m.MetricLabelNames(1, from, to)
+	check("MetricLabelNames", names, err, []string{"__name__", "code", "service"})
+
+	values, err := m.MetricLabelValues(1, "service", from, to)
+	check("MetricLabelValues", values, err, []string{"cid", "squall"})
+
+	names, err = m.Loki(2).LabelNames(from, to)
+	check("LabelNames", names, err, []string{"app", "pod"})
+
+	values, err = m.Loki(2).LabelValues("app", from, to)
+	check("LabelValues", values, err, []string{"midgard", "squall"})
+
+	if _, err := m.Loki(2).LabelValues("unknown", from, to); err == nil {
+		t.Errorf("LabelValues() should fail on an unknown label")
+	}
+}
@@ -0,0 +1,63 @@
+package monitoring
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// LatencyStats represents the latency statistics over a set of traces
+type LatencyStats struct {
+	Min     time.Duration
+	Avg     time.Duration
+	P95     time.Duration
+	Max     time.Duration
+	Slowest string
+}
+
+// String returns a short representation of the statistics
+func (l *LatencyStats) String() string {
+
+	if l == nil {
+		return ""
+	}
+
+	round := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+
+	return fmt.Sprintf("%s/%s/%s/%s", round(l.Min), round(l.Avg), round(l.P95), round(l.Max))
+}
+
+// ComputeLatency computes the latency statistics over the traces durations.
+// It returns nil if there is no traces.
+func ComputeLatency(traces []Trace) *LatencyStats {
+
+	if len(traces) == 0 {
+		return nil
+	}
+
+	durations := make([]time.Duration, len(traces))
+	stats := &LatencyStats{}
+
+	var total time.Duration
+	for i, t := range traces {
+
+		durations[i] = t.Duration()
+		total += durations[i]
+
+		if stats.Slowest == "" || durations[i] > stats.Max {
+			stats.Max = durations[i]
+			stats.Slowest = t.TraceID
+		}
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	stats.Min = durations[0]
+	stats.Avg = total / time.Duration(len(durations))
+
+	// Nearest rank percentile
+	stats.P95 = durations[int(math.Ceil(0.95*float64(len(durations))))-1]
+
+	return stats
+}
@@ -0,0 +1,65 @@
+package monitoring
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestComputeLatency(t *testing.T) {
+
+	trace := func(id string, duration time.Duration) Trace {
+		return Trace{
+			TraceID: id,
+			Spans: []Span{
+				{SpanID: "a", StartTime: 1000, Duration: duration.Microseconds()},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		traces []Trace
+		want   *LatencyStats
+	}{
+		{
+			"no traces",
+			nil,
+			nil,
+		},
+		{
+			"one trace",
+			[]Trace{trace("1", time.Second)},
+			&LatencyStats{
+				Min:     time.Second,
+				Avg:     time.Second,
+				P95:     time.Second,
+				Max:     time.Second,
+				Slowest: "1",
+			},
+		},
+		{
+			"several traces",
+			[]Trace{
+				trace("1", 200*time.Millisecond),
+				trace("2", 4*time.Second),
+				trace("3", 100*time.Millisecond),
+				trace("4", 300*time.Millisecond),
+			},
+			&LatencyStats{
+				Min:     100 * time.Millisecond,
+				Avg:     1150 * time.Millisecond,
+				P95:     4 * time.Second,
+				Max:     4 * time.Second,
+				Slowest: "2",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComputeLatency(tt.traces); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ComputeLatency() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package monitoring
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// contextLabels are the labels identifying the stream of a line when fetching its
+// context. The labels extracted by the query pipeline are not stream labels so
+// they cannot be used to select the stream.
+var contextLabels = []string{"namespace", "pod", "container", "filename", "stream"}
+
+// LogGroup is a group of consecutive lines of a stream around one or more matches
+type LogGroup struct {
+	Entries []LogEntry
+	matches map[string]struct{}
+
+	// prev and next are the keys of the lines of the stream just before and
+	// after the group, if fetched, to merge the adjacent groups
+	prev string
+	next string
+}
+
+// IsMatch returns true if the entry is one of the matches of the group
+func (g LogGroup) IsMatch(e LogEntry) bool {
+	_, ok := g.matches[contextKey(e)]
+	return ok
+}
+
+// contextKey returns a unique identifier of the entry in its stream. The
+// labels extracted by the pipeline are ignored as the context lines
+// do not have them.
+func contextKey(e LogEntry) string {
+	return fmt.Sprintf("%d|%s|%s", e.Timestamp.UnixNano(), contextSelector(e.Labels), e.Line)
+}
+
+// Context fetches up to before and after lines of the same stream around each match,
+// within the window. The lines are grouped per stream and the overlapping or adjacent
+// groups are merged. The groups are sorted by their first line.
+func (l LokiClient) Context(matches []LogEntry, from, to time.Time, before, after int) ([]LogGroup, error) {
+
+	groups := map[string][]LogGroup{}
+	order := []string{}
+
+	for _, m := range matches {
+
+		selector := contextSelector(m.Labels)
+
+		entries := []LogEntry{m}
+		prev, next := "", ""
+
+		// One more line is fetched on each side to know the line just
+		// outside of the group
+		if before > 0 {
+			// The same timestamp lines can be on either side of the match
+			res, err := l.queryRange(selector, from, m.Timestamp.Add(time.Nanosecond), before+2, false)
+			if err != nil {
+				return nil, err
+			}
+			lines := neighbours(res, m, before+1)
+			if len(lines) > before {
+				prev, lines = contextKey(lines[before]), lines[:before]
+			}
+			entries = append(entries, lines...)
+		}
+
+		if after > 0 {
+			res, err := l.queryRange(selector, m.Timestamp, to, after+2, true)
+			if err != nil {
+				return nil, err
+			}
+			lines := neighbours(res, m, after+1)
+			if len(lines) > after {
+				next, lines = contextKey(lines[after]), lines[:after]
+			}
+			entries = append(entries, lines...)
+		}
+
+		if _, ok := groups[selector]; !ok {
+			order = append(order, selector)
+		}
+
+		g := newLogGroup(entries, m)
+		g.prev, g.next = prev, next
+
+		groups[selector] = append(groups[selector], g)
+	}
+
+	merged := []LogGroup{}
+	for _, selector := range order {
+		merged = append(merged, mergeLogGroups(groups[selector])...)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Entries[0].Timestamp.Before(merged[j].Entries[0].Timestamp)
+	})
+
+	return merged, nil
+}
+
+// contextSelector returns the LogQL selector of the stream of the labels
+func contextSelector(labels LogLabels) string {
+
+	stream := LogLabels{}
+	for _, name := range contextLabels {
+		if v, ok := labels[name]; ok {
+			stream[name] = v
+		}
+	}
+
+	if len(stream) == 0 {
+		return labels.String()
+	}
+
+	return stream.String()
+}
+
+// neighbours returns up to n entries other than the match
+func neighbours(entries []LogEntry, match LogEntry, n int) []LogEntry {
+
+	res := []LogEntry{}
+	for _, e := range entries {
+		if len(res) == n {
+			break
+		}
+		if contextKey(e) != contextKey(match) {
+			res = append(res, e)
+		}
+	}
+
+	return res
+}
+
+// newLogGroup returns a group of the entries sorted by timestamp without duplicates
+func newLogGroup(entries []LogEntry, matches ...LogEntry) LogGroup {
+
+	g := LogGroup{matches: map[string]struct{}{}}
+	for _, m := range matches {
+		g.matches[contextKey(m)] = struct{}{}
+	}
+
+	seen := map[string]struct{}{}
+	for _, e := range entries {
+		if _, ok := seen[contextKey(e)]; !ok {
+			seen[contextKey(e)] = struct{}{}
+			g.Entries = append(g.Entries, e)
+		}
+	}
+
+	sort.SliceStable(g.Entries, func(i, j int) bool { return g.Entries[i].Timestamp.Before(g.Entries[j].Timestamp) })
+
+	return g
+}
+
+// adjacent returns true if the next group of the stream overlaps
+// the group or follows it without any line in between
+func (g LogGroup) adjacent(next LogGroup) bool {
+
+	last, first := g.Entries[len(g.Entries)-1], next.Entries[0]
+
+	if !first.Timestamp.After(last.Timestamp) {
+		return true
+	}
+
+	return (g.next != "" && g.next == contextKey(first)) || (next.prev != "" && next.prev == contextKey(last))
+}
+
+// mergeLogGroups merges the overlapping and adjacent groups of a stream
+func mergeLogGroups(groups []LogGroup) []LogGroup {
+
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Entries[0].Timestamp.Before(groups[j].Entries[0].Timestamp) })
+
+	merged := []LogGroup{}
+	for _, g := range groups {
+
+		if len(merged) == 0 {
+			merged = append(merged, g)
+			continue
+		}
+
+		last := &merged[len(merged)-1]
+		if !last.adjacent(g) {
+			merged = append(merged, g)
+			continue
+		}
+
+		entries := append(append([]LogEntry{}, last.Entries...), g.Entries...)
+
+		matches := []LogEntry{}
+		for _, e := range entries {
+			if last.IsMatch(e) || g.IsMatch(e) {
+				matches = append(matches, e)
+			}
+		}
+
+		// The group ending last gives the line after the merged group
+		next := last.next
+		if !g.Entries[len(g.Entries)-1].Timestamp.Before(last.Entries[len(last.Entries)-1].Timestamp) {
+			next = g.next
+		}
+
+		prev := last.prev
+		*last = newLogGroup(entries, matches...)
+		last.prev, last.next = prev, next
+	}
+
+	return merged
+}
@@ -0,0 +1,169 @@
+package monitoring
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLokiClient_Context(t *testing.T) {
+
+	base := time.Unix(1700000000, 0)
+
+	entries := []LogEntry{}
+	for i := 0; i < 10; i++ {
+		entries = append(entries, LogEntry{Timestamp: base.Add(time.Duration(i) * time.Second), Labels: LogLabels{"app": "squall", "pod": "squall-1"}, Line: fmt.Sprintf("s%d", i)})
+		entries = append(entries, LogEntry{Timestamp: base.Add(time.Duration(i) * time.Second), Labels: LogLabels{"app": "cid", "pod": "cid-1"}, Line: fmt.Sprintf("c%d", i)})
+	}
+
+	// The matches carry a label extracted by the pipeline
+	match := func(i int, pod string, line string) LogEntry {
+		return LogEntry{Timestamp: base.Add(time.Duration(i) * time.Second), Labels: LogLabels{"pod": pod, "level": "error"}, Line: line}
+	}
+
+	tests := []struct {
+		name    string
+		matches []LogEntry
+		before  int
+		after   int
+		want    string
+	}{
+		{
+			"before",
+			[]LogEntry{match(5, "squall-1", "s5")},
+			2,
+			0,
+			"s3,s4,*s5",
+		},
+		{
+			"after at the end of the window",
+			[]LogEntry{match(8, "cid-1", "c8")},
+			0,
+			3,
+			"*c8,c9",
+		},
+		{
+			"overlapping groups are merged",
+			[]LogEntry{match(2, "squall-1", "s2"), match(4, "squall-1", "s4"), match(9, "squall-1", "s9")},
+			1,
+			1,
+			"s1,*s2,s3,*s4,s5|s8,*s9",
+		},
+		{
+			"adjacent groups are merged",
+			[]LogEntry{match(2, "squall-1", "s2"), match(5, "squall-1", "s5")},
+			1,
+			1,
+			"s1,*s2,s3,s4,*s5,s6",
+		},
+		{
+			"adjacent groups without before lines are merged",
+			[]LogEntry{match(2, "squall-1", "s2"), match(4, "squall-1", "s4"), match(7, "squall-1", "s7")},
+			0,
+			1,
+			"*s2,s3,*s4,s5|*s7,s8",
+		},
+		{
+			"adjacent groups without after lines are merged",
+			[]LogEntry{match(2, "squall-1", "s2"), match(4, "squall-1", "s4"), match(7, "squall-1", "s7")},
+			1,
+			0,
+			"s1,*s2,s3,*s4|s6,*s7",
+		},
+		{
+			"streams are not mixed",
+			[]LogEntry{match(3, "squall-1", "s3"), match(4, "cid-1", "c4")},
+			1,
+			1,
+			"s2,*s3,s4|c3,*c4,c5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			calls := 0
+			server := fakeLoki(t, entries, &calls)
+			defer server.Close()
+
+			u, _ := url.Parse(server.URL)
+			m := Client{url: u, limiters: map[int]*rateLimiter{}}
+
+			groups, err := m.Loki(1).Context(tt.matches, base, base.Add(10*time.Second), tt.before, tt.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			for _, g := range groups {
+				lines := []string{}
+				for _, e := range g.Entries {
+					if g.IsMatch(e) {
+						lines = append(lines, "*"+e.Line)
+					} else {
+						lines = append(lines, e.Line)
+					}
+				}
+				got = append(got, strings.Join(lines, ","))
+			}
+
+			if strings.Join(got, "|") != tt.want {
+				t.Errorf("Context() = %s, want %s", strings.Join(got, "|"), tt.want)
+			}
+		})
+	}
+}
+
+func TestLogPrinter_Separator(t *testing.T) {
+
+	for format, want := range map[string]string{LogOutputDefault: "--\n", LogOutputRaw: "--\n", LogOutputJSONL: "", LogOutputLogfmt: ""} {
+		buf := &strings.Builder{}
+		if err := NewLogPrinter(buf, format, false, false).Separator(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != want {
+			t.Errorf("Separator(%s) = %q, want %q", format, buf.String(), want)
+		}
+	}
+}
+
+func TestLogPrinter_PrintContext(t *testing.T) {
+
+	e := LogEntry{
+		Timestamp: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC),
+		Labels:    LogLabels{"app": "squall", "pod": "squall-1"},
+		Line:      "boom",
+	}
+
+	tests := []struct {
+		name     string
+		format   string
+		noLabels bool
+		colored  bool
+		match    bool
+		want     string
+	}{
+		{"match", LogOutputDefault, false, false, true, "2023-11-14T22:13:20Z {pod=\"squall-1\"}:boom\n"},
+		{"context", LogOutputDefault, false, false, false, "2023-11-14T22:13:20Z {pod=\"squall-1\"}-boom\n"},
+		{"match no labels", LogOutputDefault, true, false, true, "2023-11-14T22:13:20Z:boom\n"},
+		{"match colored", LogOutputDefault, true, true, true, "2023-11-14T22:13:20Z\x1b[1;31m:\x1b[0mboom\n"},
+		{"context colored", LogOutputDefault, true, true, false, "2023-11-14T22:13:20Z-boom\n"},
+		{"raw", LogOutputRaw, false, false, true, "boom\n"},
+		{"jsonl", LogOutputJSONL, false, false, false, `{"timestamp":"2023-11-14T22:13:20Z","labels":{"app":"squall","pod":"squall-1"},"line":"boom","match":false}` + "\n"},
+		{"logfmt", LogOutputLogfmt, false, false, true, "ts=2023-11-14T22:13:20Z app=squall pod=squall-1 line=boom match=true\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &strings.Builder{}
+			if err := NewLogPrinter(buf, tt.format, tt.noLabels, tt.colored).PrintContext(e, tt.match); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("PrintContext() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package monitoring
+
+import (
+	"bufio"
+	"compress/gzip"
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LogManifestName is the file name of the manifest written in the log directory
+const LogManifestName = "manifest.json"
+
+// maxOpenLogFiles is the number of files kept open by a LogDirWriter. The least
+// recently written file is closed when another one must be opened.
+var maxOpenLogFiles = 64
+
+// unsafeFileChars matches the characters replaced in the file names
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// LogManifest describes the files written in a log directory
+type LogManifest struct {
+	Query   string            `json:"query"`
+	Start   time.Time         `json:"start"`
+	End     time.Time         `json:"end"`
+	SplitBy string            `json:"splitBy"`
+	Files   []LogManifestFile `json:"files"`
+}
+
+// LogManifestFile describes a file of a log directory
+type LogManifestFile struct {
+	File  string    `json:"file"`
+	Value string    `json:"value"`
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+	Lines int       `json:"lines"`
+}
+
+// logDirFile is a file of a LogDirWriter, its file is nil when closed
+type logDirFile struct {
+	file     *os.File
+	buffer   *bufio.Writer
+	gzip     *gzip.Writer
+	printer  *LogPrinter
+	manifest LogManifestFile
+	element  *list.Element
+}
+
+// close flushes and closes the file, the file is closed even if the flush fails
+func (f *logDirFile) close() error {
+
+	err := f.buffer.Flush()
+
+	if f.gzip != nil {
+		if gerr := f.gzip.Close(); err == nil {
+			err = gerr
+		}
+	}
+
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+
+	f.file, f.buffer, f.gzip, f.printer = nil, nil, nil, nil
+
+	return err
+}
+
+// LogDirWriter writes log entries in one file per stream of a directory
+type LogDirWriter struct {
+	dir      string
+	label    string
+	format   string
+	noLabels bool
+	compress bool
+	manifest LogManifest
+	files    map[string]*logDirFile
+	opened   *list.List // the open files, most recently written first
+}
+
+// NewLogDirWriter returns a LogDirWriter writing in dir in the given format. The
+// entries are split by pod, app, or label:<name>, and gzip compressed if compress is set.
+func NewLogDirWriter(dir string, splitBy string, format string, noLabels bool, compress bool) (*LogDirWriter, error) {
+
+	label := splitBy
+	switch {
+	case splitBy == "pod", splitBy == "app":
+	case strings.HasPrefix(splitBy, "label:") && len(splitBy) > len("label:"):
+		label = strings.TrimPrefix(splitBy, "label:")
+	default:
+		return nil, fmt.Errorf("invalid split %s: must be pod, app or label:<name>", splitBy)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create log directory: %w", err)
+	}
+
+	return &LogDirWriter{
+		dir:      dir,
+		label:    label,
+		format:   format,
+		noLabels: noLabels,
+		compress: compress,
+		manifest: LogManifest{SplitBy: splitBy, Files: []LogManifestFile{}},
+		files:    map[string]*logDirFile{},
+		opened:   list.New(),
+	}, nil
+}
+
+// Write writes the entry in the file of its stream
+func (w *LogDirWriter) Write(e LogEntry) error {
+
+	value := e.Labels[w.label]
+
+	f, ok := w.files[value]
+	switch {
+
+	case !ok:
+		f = &logDirFile{manifest: LogManifestFile{File: w.fileName(value), Value: value, First: e.Timestamp}}
+		if err := w.open(f, os.O_CREATE|os.O_WRONLY|os.O_TRUNC); err != nil {
+			return err
+		}
+		w.files[value] = f
+
+	case f.file == nil:
+		if err := w.open(f, os.O_WRONLY|os.O_APPEND); err != nil {
+			return err
+		}
+
+	default:
+		w.opened.MoveToFront(f.element)
+	}
+
+	if err := f.printer.Print(e); err != nil {
+		return fmt.Errorf("unable to write logs to %s: %w", f.manifest.File, err)
+	}
+
+	f.manifest.Lines++
+	if e.Timestamp.Before(f.manifest.First) {
+		f.manifest.First = e.Timestamp
+	}
+	if e.Timestamp.After(f.manifest.Last) {
+		f.manifest.Last = e.Timestamp
+	}
+
+	return nil
+}
+
+// fileName returns a file name for the value not taken by another stream
+func (w *LogDirWriter) fileName(value string) string {
+
+	name := unsafeFileChars.ReplaceAllString(value, "_")
+	if name == "" {
+		name = "unknown"
+	}
+
+	ext := ".log"
+	if w.format == LogOutputJSONL {
+		ext = ".jsonl"
+	}
+	if w.compress {
+		ext += ".gz"
+	}
+
+	// Different values can have the same file name once sanitized
+	file := name + ext
+	for i := 2; w.taken(file); i++ {
+		file = fmt.Sprintf("%s-%d%s", name, i, ext)
+	}
+
+	return file
+}
+
+// open opens the file of a stream with the given flags, closing the least recently
+// written file if too many are open. A reopened file is appended to, with a new
+// gzip member if compressed which gzip readers concatenate.
+func (w *LogDirWriter) open(f *logDirFile, flag int) error {
+
+	if w.opened.Len() >= maxOpenLogFiles {
+		oldest := w.opened.Remove(w.opened.Back()).(*logDirFile)
+		if err := oldest.close(); err != nil {
+			return fmt.Errorf("unable to close log file %s: %w", oldest.manifest.File, err)
+		}
+	}
+
+	fd, err := os.OpenFile(filepath.Join(w.dir, f.manifest.File), flag, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to open log file: %w", err)
+	}
+
+	f.file = fd
+
+	var out io.Writer = fd
+	if w.compress {
+		f.gzip = gzip.NewWriter(fd)
+		out = f.gzip
+	}
+
+	f.buffer = bufio.NewWriter(out)
+	f.printer = NewLogPrinter(f.buffer, w.format, w.noLabels, false)
+	f.element = w.opened.PushFront(f)
+
+	return nil
+}
+
+// taken returns true if a file already has that name
+func (w *LogDirWriter) taken(file string) bool {
+
+	for _, f := range w.files {
+		if f.manifest.File == file {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Close closes the files and writes the manifest of the query. The manifest
+// is written even if some files cannot be closed, then the error is returned.
+func (w *LogDirWriter) Close(query string, start, end time.Time) (LogManifest, error) {
+
+	w.manifest.Query, w.manifest.Start, w.manifest.End = query, start, end
+
+	var errs []string
+	for _, f := range w.files {
+		if f.file != nil {
+			w.opened.Remove(f.element)
+			if err := f.close(); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", f.manifest.File, err))
+			}
+		}
+		w.manifest.Files = append(w.manifest.Files, f.manifest)
+	}
+
+	sort.Strings(errs)
+	sort.Slice(w.manifest.Files, func(i, j int) bool { return w.manifest.Files[i].File < w.manifest.Files[j].File })
+
+	data, err := json.MarshalIndent(w.manifest, "", "  ")
+	if err != nil {
+		return w.manifest, fmt.Errorf("unable to encode manifest: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(w.dir, LogManifestName), data, 0o644); err != nil {
+		return w.manifest, fmt.Errorf("unable to write manifest: %w", err)
+	}
+
+	if len(errs) > 0 {
+		return w.manifest, fmt.Errorf("unable to close log files: %s", strings.Join(errs, ", "))
+	}
+
+	return w.manifest, nil
+}
@@ -0,0 +1,255 @@
+package monitoring
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLogDirWriter(t *testing.T) {
+
+	base := time.Unix(1700000000, 0).UTC()
+	entries := []LogEntry{
+		{Timestamp: base, Labels: LogLabels{"app": "squall", "pod": "squall-1", "team": "core"}, Line: "a"},
+		{Timestamp: base.Add(time.Second), Labels: LogLabels{"app": "squall", "pod": "squall-2", "team": "core"}, Line: "b"},
+		{Timestamp: base.Add(2 * time.Second), Labels: LogLabels{"app": "squall", "pod": "squall-1", "team": "core"}, Line: "c"},
+		{Timestamp: base.Add(3 * time.Second), Labels: LogLabels{"app": "cid", "pod": "cid/1"}, Line: "d"},
+	}
+
+	tests := []struct {
+		name     string
+		splitBy  string
+		compress bool
+		want     map[string]string
+		wantErr  bool
+	}{
+		{
+			"pod",
+			"pod",
+			false,
+			map[string]string{"squall-1.log": "a\nc\n", "squall-2.log": "b\n", "cid_1.log": "d\n"},
+			false,
+		},
+		{
+			"app compressed",
+			"app",
+			true,
+			map[string]string{"squall.log.gz": "a\nb\nc\n", "cid.log.gz": "d\n"},
+			false,
+		},
+		{
+			"label",
+			"label:team",
+			false,
+			map[string]string{"core.log": "a\nb\nc\n", "unknown.log": "d\n"},
+			false,
+		},
+		{
+			"invalid",
+			"label:",
+			false,
+			nil,
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			dir := filepath.Join(t.TempDir(), "logs")
+
+			w, err := NewLogDirWriter(dir, tt.splitBy, LogOutputRaw, false, tt.compress)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewLogDirWriter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			for _, e := range entries {
+				if err := w.Write(e); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			manifest, err := w.Close("{app=~\".+\"}", base, base.Add(time.Minute))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for file, want := range tt.want {
+
+				data, err := readLogFile(filepath.Join(dir, file), tt.compress)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if string(data) != want {
+					t.Errorf("%s = %q, want %q", file, data, want)
+				}
+			}
+
+			data, err := os.ReadFile(filepath.Join(dir, LogManifestName))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			written := LogManifest{}
+			if err := json.Unmarshal(data, &written); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(written, manifest) {
+				t.Errorf("manifest = %+v, want %+v", written, manifest)
+			}
+
+			lines := 0
+			for _, f := range manifest.Files {
+				if _, ok := tt.want[f.File]; !ok {
+					t.Errorf("unexpected file %s", f.File)
+				}
+				lines += f.Lines
+			}
+			if lines != len(entries) || len(manifest.Files) != len(tt.want) {
+				t.Errorf("manifest = %+v", manifest)
+			}
+		})
+	}
+}
+
+func TestLogDirWriter_Manifest(t *testing.T) {
+
+	base := time.Unix(1700000000, 0).UTC()
+
+	w, err := NewLogDirWriter(t.TempDir(), "pod", LogOutputDefault, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, i := range []int{3, 1, 7} {
+		if err := w.Write(LogEntry{Timestamp: base.Add(time.Duration(i) * time.Second), Labels: LogLabels{"pod": "squall-1"}, Line: "x"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manifest, err := w.Close("{}", base, base.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []LogManifestFile{{File: "squall-1.log", Value: "squall-1", First: base.Add(time.Second), Last: base.Add(7 * time.Second), Lines: 3}}
+	if !reflect.DeepEqual(manifest.Files, want) {
+		t.Errorf("Files = %+v, want %+v", manifest.Files, want)
+	}
+}
+
+func TestLogDirWriter_MaxOpenFiles(t *testing.T) {
+
+	max := maxOpenLogFiles
+	t.Cleanup(func() { maxOpenLogFiles = max })
+	maxOpenLogFiles = 2
+
+	base := time.Unix(1700000000, 0).UTC()
+
+	for _, compress := range []bool{false, true} {
+
+		dir := t.TempDir()
+
+		w, err := NewLogDirWriter(dir, "pod", LogOutputRaw, false, compress)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The pods are written in turn so every write reopens a file
+		for i := 0; i < 9; i++ {
+			pod := fmt.Sprintf("squall-%d", i%3)
+			if err := w.Write(LogEntry{Timestamp: base.Add(time.Duration(i) * time.Second), Labels: LogLabels{"pod": pod}, Line: fmt.Sprintf("l%d", i)}); err != nil {
+				t.Fatal(err)
+			}
+			if w.opened.Len() > maxOpenLogFiles {
+				t.Fatalf("%d files open", w.opened.Len())
+			}
+		}
+
+		manifest, err := w.Close("{}", base, base.Add(time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, f := range manifest.Files {
+
+			data, err := readLogFile(filepath.Join(dir, f.File), compress)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := fmt.Sprintf("l%d\nl%d\nl%d\n", i, i+3, i+6); string(data) != want || f.Lines != 3 {
+				t.Errorf("compress %v: %s = %q with %d lines, want %q", compress, f.File, data, f.Lines, want)
+			}
+		}
+	}
+}
+
+func TestLogDirWriter_CloseError(t *testing.T) {
+
+	base := time.Unix(1700000000, 0).UTC()
+	dir := t.TempDir()
+
+	w, err := NewLogDirWriter(dir, "pod", LogOutputRaw, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pod := range []string{"squall-1", "squall-2"} {
+		if err := w.Write(LogEntry{Timestamp: base, Labels: LogLabels{"pod": pod}, Line: "x"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The buffered line cannot be flushed to a closed file
+	w.files["squall-1"].file.Close() // nolint
+
+	manifest, err := w.Close("{}", base, base.Add(time.Minute))
+	if err == nil {
+		t.Fatal("Close() should fail")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, LogManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	written := LogManifest{}
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(written, manifest) || len(written.Files) != 2 {
+		t.Errorf("manifest = %+v, want %+v", written, manifest)
+	}
+}
+
+// readLogFile returns the content of a log file, uncompressed if needed
+func readLogFile(path string, compressed bool) ([]byte, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint
+
+	var r io.Reader = f
+	if compressed {
+		if r, err = gzip.NewReader(f); err != nil {
+			return nil, err
+		}
+	}
+
+	return io.ReadAll(r)
+}
@@ -0,0 +1,118 @@
+package monitoring
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// logQLLabel matches the label names extracted by the LogQL json parser
+var logQLLabel = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// LogSelector describes a log query. It is turned into a
+// LogQL query with every value correctly escaped.
+type LogSelector struct {
+	Services   []string
+	Pods       []string
+	Containers []string
+	Contains   []string
+	Regexps    []string
+	Excludes   []string
+	JSONFields map[string]string
+
+	// Matchers are stream matchers added to the ones of the services, pods and containers
+	Matchers []string
+
+	// Filter is appended verbatim to the query. If there is nothing
+	// to select the streams, it must be a full LogQL query.
+	Filter string
+}
+
+// LogQL returns the LogQL query of the selector
+func (s LogSelector) LogQL() (string, error) {
+
+	matchers := []string{}
+	for _, m := range []struct {
+		label  string
+		values []string
+	}{
+		{"app", s.Services},
+		{"pod", s.Pods},
+		{"container", s.Containers},
+	} {
+		if matcher := labelMatcher(m.label, m.values); matcher != "" {
+			matchers = append(matchers, matcher)
+		}
+	}
+	matchers = append(matchers, s.Matchers...)
+
+	if len(matchers) == 0 && s.Filter == "" {
+		return "", fmt.Errorf("at least one --service, --pod, --container or a full LogQL --log-filter is required")
+	}
+
+	// Without stream matchers, the filter is the full query the pipeline is appended to
+	stages := []string{}
+	if len(matchers) == 0 {
+		stages = append(stages, s.Filter)
+	} else {
+		stages = append(stages, "{"+strings.Join(matchers, ", ")+"}")
+	}
+
+	for _, c := range s.Contains {
+		stages = append(stages, "|= "+strconv.Quote(c))
+	}
+
+	for _, r := range s.Regexps {
+		if _, err := regexp.Compile(r); err != nil {
+			return "", fmt.Errorf("invalid --regex %s: %w", r, err)
+		}
+		stages = append(stages, "|~ "+strconv.Quote(r))
+	}
+
+	for _, e := range s.Excludes {
+		stages = append(stages, "!= "+strconv.Quote(e))
+	}
+
+	if len(s.JSONFields) > 0 {
+
+		keys := make([]string, 0, len(s.JSONFields))
+		for k := range s.JSONFields {
+			if !logQLLabel.MatchString(k) {
+				return "", fmt.Errorf("invalid --json-field %s: nested fields are flattened with _ and must match %s", k, logQLLabel)
+			}
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		stages = append(stages, "| json")
+		for _, k := range keys {
+			stages = append(stages, fmt.Sprintf("| %s=%s", k, strconv.Quote(s.JSONFields[k])))
+		}
+	}
+
+	if len(matchers) > 0 && s.Filter != "" {
+		stages = append(stages, s.Filter)
+	}
+
+	return strings.Join(stages, " "), nil
+}
+
+// labelMatcher returns the stream matcher of a label matching exactly one of the values
+func labelMatcher(label string, values []string) string {
+
+	switch len(values) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("%s=%s", label, strconv.Quote(values[0]))
+	}
+
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = regexp.QuoteMeta(v)
+	}
+
+	return fmt.Sprintf("%s=~%s", label, strconv.Quote(strings.Join(quoted, "|")))
+}
@@ -0,0 +1,83 @@
+package monitoring
+
+import (
+	"testing"
+)
+
+func TestLogSelector_LogQL(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		selector LogSelector
+		want     string
+		wantErr  bool
+	}{
+		{
+			"service",
+			LogSelector{Services: []string{"squall"}},
+			`{app="squall"}`,
+			false,
+		},
+		{
+			"services are escaped",
+			LogSelector{Services: []string{"squall", "wutai.api"}, Pods: []string{"squall-1"}},
+			`{app=~"squall|wutai\\.api", pod="squall-1"}`,
+			false,
+		},
+		{
+			"pipeline",
+			LogSelector{
+				Containers: []string{"cid"},
+				Contains:   []string{`say "hello"`},
+				Regexps:    []string{`code=5\d\d`},
+				Excludes:   []string{"health"},
+				JSONFields: map[string]string{"ns": "/foo", "level": "error"},
+				Filter:     `| line_format "{{.msg}}"`,
+			},
+			`{container="cid"} |= "say \"hello\"" |~ "code=5\\d\\d" != "health" | json | level="error" | ns="/foo" | line_format "{{.msg}}"`,
+			false,
+		},
+		{
+			"raw filter",
+			LogSelector{Filter: `{type="aporeto"} |~ "ERROR"`},
+			`{type="aporeto"} |~ "ERROR"`,
+			false,
+		},
+		{
+			"nothing to select",
+			LogSelector{},
+			"",
+			true,
+		},
+		{
+			"pipeline appended to the raw filter",
+			LogSelector{Contains: []string{"boom"}, JSONFields: map[string]string{"ns": "/foo"}, Filter: `{type="aporeto"} |~ "ERROR"`},
+			`{type="aporeto"} |~ "ERROR" |= "boom" | json | ns="/foo"`,
+			false,
+		},
+		{
+			"invalid regex",
+			LogSelector{Services: []string{"squall"}, Regexps: []string{"("}},
+			"",
+			true,
+		},
+		{
+			"invalid json field",
+			LogSelector{Services: []string{"squall"}, JSONFields: map[string]string{"req.ns": "/"}},
+			"",
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.selector.LogQL()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LogQL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("LogQL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
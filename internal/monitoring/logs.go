@@ -2,71 +2,413 @@
 package monitoring
 
 import (
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aporeto-inc/tracer/internal/configuration"
-	"github.com/grafana/loki/pkg/logcli/client"
-	"github.com/grafana/loki/pkg/logcli/output"
-	"github.com/grafana/loki/pkg/logcli/query"
-	"github.com/prometheus/common/config"
 )
 
-// GetLogs try to get the logs for a service and and a time window
-func (m Client) GetLogs(proxy int, from, to time.Time, services []string, cfg configuration.LogConf, quiet bool) error {
-	lokiProxy, err := m.url.Parse(fmt.Sprintf("api/datasources/proxy/%d", proxy))
+const (
+	// LogOutputDefault displays the timestamp, the pod and the line
+	LogOutputDefault = "default"
+
+	// LogOutputRaw displays only the line
+	LogOutputRaw = "raw"
+
+	// LogOutputJSONL displays one JSON object per entry
+	LogOutputJSONL = "jsonl"
+
+	// LogOutputLogfmt displays one logfmt record per entry
+	LogOutputLogfmt = "logfmt"
+)
+
+// displayedLabels are the labels displayed along the log lines
+var displayedLabels = []string{"pod"}
+
+// labelColors are the ANSI colors used for the labels of the streams
+var labelColors = []string{"31", "32", "33", "34", "35", "36", "91", "92", "93", "94", "95", "96"}
+
+// LogPrinter writes log entries to an output
+type LogPrinter struct {
+	w        io.Writer
+	format   string
+	noLabels bool
+	colored  bool
+}
+
+// NewLogPrinter returns a LogPrinter writing to w in the given format
+func NewLogPrinter(w io.Writer, format string, noLabels bool, colored bool) *LogPrinter {
+	return &LogPrinter{w: w, format: format, noLabels: noLabels, colored: colored}
+}
+
+// Print writes a log entry
+func (p *LogPrinter) Print(e LogEntry) error {
+	return p.print(e, nil)
+}
+
+// PrintContext writes a log entry of a context group marked grep style,
+// with : after the labels for a match and - for a context line. The raw
+// lines are not marked.
+func (p *LogPrinter) PrintContext(e LogEntry, match bool) error {
+	return p.print(e, &match)
+}
+
+// print writes a log entry, marked if match is set
+func (p *LogPrinter) print(e LogEntry, match *bool) error {
+
+	line := strings.TrimRight(e.Line, "\n")
+
+	var err error
+
+	switch p.format {
+
+	case LogOutputRaw:
+		_, err = fmt.Fprintln(p.w, line)
+
+	case LogOutputJSONL:
+		err = json.NewEncoder(p.w).Encode(struct {
+			Timestamp time.Time `json:"timestamp"`
+			Labels    LogLabels `json:"labels"`
+			Line      string    `json:"line"`
+			Match     *bool     `json:"match,omitempty"`
+		}{e.Timestamp, e.Labels, line, match})
+
+	case LogOutputLogfmt:
+		record := logfmt(e, line)
+		if match != nil {
+			record += fmt.Sprintf(" match=%t", *match)
+		}
+		_, err = fmt.Fprintln(p.w, record)
+
+	default:
+
+		sep := " "
+		if match != nil {
+			sep = "-"
+			if *match {
+				sep = p.highlight(":")
+			}
+		}
+
+		// Zap lines are pretty printed with their own timestamp
+		ts := e.Timestamp
+		if z, ok := ParseZapLine(line); ok {
+			line = z.pretty(p.colored)
+			if !z.Time.IsZero() {
+				ts = z.Time.In(e.Timestamp.Location())
+			}
+		}
+
+		if p.noLabels {
+			_, err = fmt.Fprintf(p.w, "%s%s%s\n", ts.Format(time.RFC3339), sep, line)
+			break
+		}
+
+		labels := LogLabels{}
+		for _, name := range displayedLabels {
+			if v, ok := e.Labels[name]; ok {
+				labels[name] = v
+			}
+		}
+
+		_, err = fmt.Fprintf(p.w, "%s %s%s%s\n", ts.Format(time.RFC3339), p.color(labels.String(), e.Labels.String()), sep, line)
+	}
+
+	return err
+}
+
+// Separator writes the separator between two groups of lines, if the format has one
+func (p *LogPrinter) Separator() error {
+
+	switch p.format {
+	case LogOutputJSONL, LogOutputLogfmt:
+		return nil
+	}
+
+	_, err := fmt.Fprintln(p.w, "--")
+	return err
+}
+
+// color colorizes s with a color chosen from the stream
+func (p *LogPrinter) color(s string, stream string) string {
+
+	if !p.colored {
+		return s
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(stream)) // nolint
+
+	return fmt.Sprintf("\x1b[%sm%s\x1b[0m", labelColors[h.Sum32()%uint32(len(labelColors))], s)
+}
+
+// highlight colorizes the mark of a match in bold red like grep
+func (p *LogPrinter) highlight(s string) string {
+
+	if !p.colored {
+		return s
+	}
+
+	return fmt.Sprintf("\x1b[1;31m%s\x1b[0m", s)
+}
+
+// logfmt returns the entry as a logfmt record with the
+// timestamp, the labels sorted by name and the line
+func logfmt(e LogEntry, line string) string {
+
+	names := make([]string, 0, len(e.Labels))
+	for name := range e.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := []string{"ts=" + e.Timestamp.Format(time.RFC3339Nano)}
+	for _, name := range names {
+		fields = append(fields, name+"="+logfmtValue(e.Labels[name]))
+	}
+	fields = append(fields, "line="+logfmtValue(line))
+
+	return strings.Join(fields, " ")
+}
+
+// logfmtValue quotes the value if needed
+func logfmtValue(v string) string {
+
+	if v == "" || strings.ContainsAny(v, " =\"\\") || strings.IndexFunc(v, func(r rune) bool { return r < ' ' }) >= 0 {
+		return strconv.Quote(v)
+	}
+
+	return v
+}
+
+// useColors returns true if the output must be colorized
+// according to the color mode
+func useColors(mode string, out *os.File) bool {
+
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+
+	return IsTerminal(out)
+}
+
+// IsTerminal returns true if the file is a terminal
+func IsTerminal(f *os.File) bool {
+
+	info, err := f.Stat()
 	if err != nil {
-		panic(err)
+		return false
 	}
 
-	client := &client.DefaultClient{
-		TLSConfig: config.TLSConfig{
-			CAFile:   m.cfg.MonitoringCAPath,
-			CertFile: m.cfg.MonitoringCertPath,
-			KeyFile:  m.cfg.MonitoringCertKeyPath,
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// GetLogs try to get the logs matching the LogQL query in a time window.
+// Only the zap JSON lines matching the fields and the minimum level are kept if any is set.
+func (m Client) GetLogs(proxy int, from, to time.Time, query string, fields map[string]string, cfg configuration.LogConf) error {
+
+	q := LogQuery{
+		Query:   query,
+		Start:   from,
+		End:     to,
+		Limit:   cfg.LogLines,
+		Forward: cfg.Direction == "forward",
+		Follow:  cfg.Follow,
+		Tail:    cfg.LogLines,
+		OnStatus: func(s FollowStatus) {
+			fmt.Fprintln(os.Stderr, s)
 		},
-		Address: m.url.ResolveReference(lokiProxy).String(),
 	}
 
-	q := &query.Query{
-		QueryString: func() string {
-			if len(services) > 0 {
-				return fmt.Sprintf(`{app=~"%s"} %s`, strings.Join(services, "|"), cfg.LogFilter)
+	filter := ZapFilter{MinLevel: cfg.MinLevel, Fields: fields}
+
+	// The filter is applied on our side so the limit is
+	// applied on the printed lines. When following, the
+	// last lines of the window are printed then the logs
+	// are streamed until interrupted.
+	if q.Follow || !filter.IsEmpty() {
+		q.Limit = 0
+	}
+
+	printer := NewLogPrinter(os.Stdout, cfg.LogOutput, cfg.LogNoLabels, useColors(cfg.Color, os.Stdout))
+
+	if cfg.LogDir != "" {
+
+		if cfg.Follow {
+			return fmt.Errorf("the logs cannot be written to a directory when following them")
+		}
+
+		return m.writeLogDir(proxy, q, filter, cfg)
+	}
+
+	before, after := cfg.BeforeContext, cfg.AfterContext
+	if before == 0 {
+		before = cfg.Context
+	}
+	if after == 0 {
+		after = cfg.Context
+	}
+
+	if before > 0 || after > 0 {
+
+		if cfg.Follow {
+			return fmt.Errorf("the context lines cannot be displayed when following the logs")
+		}
+
+		return m.printLogContext(proxy, q, filter, cfg.LogLines, before, after, printer)
+	}
+
+	printed := 0
+
+	it := m.Loki(proxy).Query(q)
+	for it.Next() {
+
+		if !filter.Match(it.Entry()) {
+			continue
+		}
+
+		if !cfg.Follow && cfg.LogLines > 0 && printed == cfg.LogLines {
+			break
+		}
+		printed++
+
+		if err := printer.Print(it.Entry()); err != nil {
+			return fmt.Errorf("unable to print logs: %w", err)
+		}
+	}
+
+	return it.Err()
+}
+
+// GetLogPatterns groups the logs matching the LogQL query in a time window into patterns.
+// Every line of the window is retrieved, and filtered on the fields and the minimum level like GetLogs.
+func (m Client) GetLogPatterns(proxy int, from, to time.Time, query string, fields map[string]string, cfg configuration.LogConf) ([]LogPattern, error) {
+
+	filter := ZapFilter{MinLevel: cfg.MinLevel, Fields: fields}
+	patterns := NewLogPatterns()
+
+	it := m.Loki(proxy).Query(LogQuery{Query: query, Start: from, End: to, Forward: true})
+	for it.Next() {
+		if filter.Match(it.Entry()) {
+			patterns.Add(it.Entry())
+		}
+	}
+
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return patterns.Patterns(), nil
+}
+
+// printLogContext prints up to lines matches of the query with the lines before and
+// after them in their stream, grouped grep style
+func (m Client) printLogContext(proxy int, q LogQuery, filter ZapFilter, lines, before, after int, printer *LogPrinter) error {
+
+	loki := m.Loki(proxy)
+
+	matches := []LogEntry{}
+
+	it := loki.Query(q)
+	for it.Next() {
+
+		if !filter.Match(it.Entry()) {
+			continue
+		}
+
+		if lines > 0 && len(matches) == lines {
+			break
+		}
+
+		matches = append(matches, it.Entry())
+	}
+
+	if err := it.Err(); err != nil {
+		return err
+	}
+
+	groups, err := loki.Context(matches, q.Start, q.End, before, after)
+	if err != nil {
+		return err
+	}
+
+	if !q.Forward {
+		for i, j := 0, len(groups)-1; i < j; i, j = i+1, j-1 {
+			groups[i], groups[j] = groups[j], groups[i]
+		}
+	}
+
+	for i, g := range groups {
+
+		if i > 0 {
+			if err := printer.Separator(); err != nil {
+				return fmt.Errorf("unable to print logs: %w", err)
+			}
+		}
+
+		for _, e := range g.Entries {
+			if err := printer.PrintContext(e, g.IsMatch(e)); err != nil {
+				return fmt.Errorf("unable to print logs: %w", err)
 			}
-			return cfg.LogFilter
-		}(),
-		Start:           from,
-		End:             to,
-		Limit:           cfg.LogLines,
-		BatchSize:       100,
-		Quiet:           quiet,
-		NoLabels:        cfg.LogNoLabels,
-		ShowLabelsKey:   []string{"pod"},
-		IgnoreLabelsKey: []string{"filename", "stream"},
-		ColoredOutput:   true,
+		}
 	}
 
-	outputOptions := &output.LogOutputOptions{
-		NoLabels:      q.NoLabels,
-		ColoredOutput: q.ColoredOutput,
+	return nil
+}
+
+// writeLogDir writes all the lines matching the query in one file per stream of the log
+// directory. The lines are fetched forward by pages of cfg.LogLines, or of the
+// default batch size if it is not set.
+func (m Client) writeLogDir(proxy int, q LogQuery, filter ZapFilter, cfg configuration.LogConf) error {
+
+	w, err := NewLogDirWriter(cfg.LogDir, cfg.SplitBy, cfg.LogOutput, cfg.LogNoLabels, cfg.Gzip)
+	if err != nil {
+		return err
 	}
 
-	if cfg.Direction == "forward" {
-		q.Forward = true
+	q.Limit, q.BatchSize, q.Forward = 0, cfg.LogLines, true
+
+	it := m.Loki(proxy).Query(q)
+	for it.Next() {
+		if filter.Match(it.Entry()) {
+			if err := w.Write(it.Entry()); err != nil {
+				w.Close(q.Query, q.Start, q.End) // nolint
+				return err
+			}
+		}
 	}
 
-	out, err := output.NewLogOutput(os.Stdout, "default", outputOptions)
+	manifest, err := w.Close(q.Query, q.Start, q.End)
 	if err != nil {
 		return err
 	}
 
-	if cfg.Follow {
-		q.TailQuery(0, client, out)
-	} else {
-		q.DoQuery(client, out, false)
+	// The files written so far are kept with their manifest
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("logs partially written to %s: %w", cfg.LogDir, err)
+	}
+
+	lines := 0
+	for _, f := range manifest.Files {
+		lines += f.Lines
 	}
 
+	fmt.Printf("> %d lines written to %d files in %s, see %s.\n", lines, len(manifest.Files), cfg.LogDir, filepath.Join(cfg.LogDir, LogManifestName))
+
 	return nil
 }
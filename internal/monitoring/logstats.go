@@ -0,0 +1,196 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// sparkBlocks are the characters of a sparkline by increasing value
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// LogSeries is a series returned by a LogQL metric query.
+// There is one point per step over the window, missing points are 0.
+type LogSeries struct {
+	Labels LogLabels
+	Start  time.Time
+	Step   time.Duration
+	Values []float64
+}
+
+// Total returns the sum of the values
+func (s LogSeries) Total() float64 {
+
+	total := 0.0
+	for _, v := range s.Values {
+		total += v
+	}
+
+	return total
+}
+
+// Max returns the maximum value
+func (s LogSeries) Max() float64 {
+
+	max := 0.0
+	for _, v := range s.Values {
+		max = math.Max(max, v)
+	}
+
+	return max
+}
+
+// Sparkline returns the values as a sparkline scaled from 0 to the maximum
+func (s LogSeries) Sparkline() string {
+
+	max := s.Max()
+
+	b := &strings.Builder{}
+	for _, v := range s.Values {
+		i := 0
+		if max > 0 {
+			i = int(math.Round(v / max * float64(len(sparkBlocks)-1)))
+		}
+		b.WriteRune(sparkBlocks[i])
+	}
+
+	return b.String()
+}
+
+// lokiMatrixResponse is the response of the loki query_range endpoint for a metric query
+type lokiMatrixResponse struct {
+	Data struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric LogLabels    `json:"metric"`
+			Values []lokiSample `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// lokiSample is a [timestamp, value] pair of a loki matrix
+type lokiSample struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *lokiSample) UnmarshalJSON(data []byte) error {
+
+	var raw []any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if len(raw) != 2 {
+		return fmt.Errorf("invalid sample: %s", data)
+	}
+
+	ts, ok := raw[0].(float64)
+	if !ok {
+		return fmt.Errorf("invalid sample timestamp: %v", raw[0])
+	}
+
+	value, ok := raw[1].(string)
+	if !ok {
+		return fmt.Errorf("invalid sample value: %v", raw[1])
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid sample value: %w", err)
+	}
+
+	sec, frac := math.Modf(ts)
+	s.Timestamp = time.Unix(int64(sec), int64(math.Round(frac*1e3))*int64(time.Millisecond))
+	s.Value = v
+
+	return nil
+}
+
+// QueryMetric runs a LogQL metric query over the window with the given step.
+// The series are sorted by decreasing total.
+func (l LokiClient) QueryMetric(query string, start, end time.Time, step time.Duration) ([]LogSeries, error) {
+
+	if step <= 0 {
+		return nil, fmt.Errorf("the step must be positive")
+	}
+
+	// Align the window on the step so the points fall into the buckets
+	start = start.Truncate(step)
+
+	values := url.Values{}
+	values.Set("query", query)
+	values.Set("start", strconv.FormatInt(start.UnixNano(), 10))
+	values.Set("end", strconv.FormatInt(end.UnixNano(), 10))
+	values.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	zap.L().Debug("Querying loki metrics",
+		zap.String("query", query),
+		zap.Time("start", start),
+		zap.Time("end", end),
+		zap.Duration("step", step),
+	)
+
+	res := &lokiMatrixResponse{}
+	if err := l.client.getJSON(l.proxy, "loki/api/v1/query_range", values, res); err != nil {
+		return nil, fmt.Errorf("unable to query log metrics: %w", err)
+	}
+
+	if res.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("unable to query log metrics: expected a metric query, got %s", res.Data.ResultType)
+	}
+
+	buckets := int(end.Sub(start)/step) + 1
+
+	series := make([]LogSeries, 0, len(res.Data.Result))
+	for _, r := range res.Data.Result {
+
+		s := LogSeries{Labels: r.Metric, Start: start, Step: step, Values: make([]float64, buckets)}
+		for _, v := range r.Values {
+			if i := int(v.Timestamp.Sub(start) / step); i >= 0 && i < buckets {
+				s.Values[i] += v.Value
+			}
+		}
+
+		series = append(series, s)
+	}
+
+	sort.SliceStable(series, func(i, j int) bool { return series[i].Total() > series[j].Total() })
+
+	return series, nil
+}
+
+// ErrorVolumeQuery returns the LogQL metric query counting the error lines per
+// service over each step. If the selector does not select any stream, all the
+// services are considered.
+func ErrorVolumeQuery(selector LogSelector, step time.Duration) (string, error) {
+
+	if len(selector.Services) == 0 && len(selector.Pods) == 0 && len(selector.Containers) == 0 && !strings.HasPrefix(strings.TrimSpace(selector.Filter), "{") {
+		selector.Matchers = append(selector.Matchers, `app=~".+"`)
+	}
+
+	query, err := selector.LogQL()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`sum by (app) (count_over_time(%s |~ %s [%s]))`, query, strconv.Quote(`(?i)\berror\b`), logQLDuration(step)), nil
+}
+
+// logQLDuration returns a duration in the LogQL format
+func logQLDuration(d time.Duration) string {
+
+	if d%time.Second != 0 {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+
+	return fmt.Sprintf("%ds", int64(d.Seconds()))
+}
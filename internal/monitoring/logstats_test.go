@@ -0,0 +1,111 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLokiClient_QueryMetric(t *testing.T) {
+
+	start := time.Unix(1700000000, 0)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.URL.Query().Get("step") != "60" {
+			t.Errorf("step = %s", r.URL.Query().Get("step"))
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{ // nolint
+			"status": "success",
+			"data": map[string]any{
+				"resultType": "matrix",
+				"result": []map[string]any{
+					{"metric": map[string]string{"app": "cid"}, "values": [][]any{{1700000040, "1"}}},
+					{"metric": map[string]string{"app": "squall"}, "values": [][]any{{1699999980, "2"}, {1700000100, "8"}, {1700000160, "4"}}},
+				},
+			},
+		})
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	m := Client{url: u, limiters: map[int]*rateLimiter{}}
+
+	series, err := m.Loki(1).QueryMetric("sum by (app) (count_over_time({app=~\".+\"}[1m]))", start, start.Add(3*time.Minute), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(series) != 2 || series[0].Labels["app"] != "squall" || series[1].Labels["app"] != "cid" {
+		t.Fatalf("QueryMetric() = %+v", series)
+	}
+
+	// The window is aligned on the step: 1699999980
+	if want := []float64{2, 0, 8, 4}; !reflect.DeepEqual(series[0].Values, want) {
+		t.Errorf("Values = %v, want %v", series[0].Values, want)
+	}
+
+	if series[0].Total() != 14 || series[0].Max() != 8 {
+		t.Errorf("Total() = %v, Max() = %v", series[0].Total(), series[0].Max())
+	}
+
+	if s := series[0].Sparkline(); s != "▃▁█▅" {
+		t.Errorf("Sparkline() = %s", s)
+	}
+
+	if s := (LogSeries{Values: []float64{0, 0}}).Sparkline(); s != "▁▁" {
+		t.Errorf("Sparkline() = %s", s)
+	}
+}
+
+func TestErrorVolumeQuery(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		selector LogSelector
+		step     time.Duration
+		want     string
+	}{
+		{
+			"all services",
+			LogSelector{},
+			time.Minute,
+			`sum by (app) (count_over_time({app=~".+"} |~ "(?i)\\berror\\b" [60s]))`,
+		},
+		{
+			"pipeline without services",
+			LogSelector{Contains: []string{"boom"}, Filter: `|= "x"`},
+			time.Minute,
+			`sum by (app) (count_over_time({app=~".+"} |= "boom" |= "x" |~ "(?i)\\berror\\b" [60s]))`,
+		},
+		{
+			"full query",
+			LogSelector{Contains: []string{"boom"}, Filter: `{type="aporeto"}`},
+			time.Minute,
+			`sum by (app) (count_over_time({type="aporeto"} |= "boom" |~ "(?i)\\berror\\b" [60s]))`,
+		},
+		{
+			"selected services",
+			LogSelector{Services: []string{"squall", "cid"}, Excludes: []string{"health"}},
+			1500 * time.Millisecond,
+			`sum by (app) (count_over_time({app=~"squall|cid"} != "health" |~ "(?i)\\berror\\b" [1500ms]))`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ErrorVolumeQuery(tt.selector, tt.step)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ErrorVolumeQuery() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,444 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultBatchSize is the number of entries requested to loki at once
+const defaultBatchSize = 1000
+
+// followInterval is the interval between two polls when following the logs.
+// The datasource proxy does not reliably forward websockets so the loki
+// tail endpoint cannot be used.
+var followInterval = 2 * time.Second
+
+// followBackoff is the initial backoff before reconnecting when following
+// the logs, doubled at each failed attempt up to followMaxBackoff
+var followBackoff = time.Second
+
+// followMaxBackoff is the maximum backoff before reconnecting
+var followMaxBackoff = 30 * time.Second
+
+// followMaxDisconnect is the time after which the reconnection attempts
+// stop and the last error is returned
+var followMaxDisconnect = 10 * time.Minute
+
+// LogLabels are the labels of a log stream
+type LogLabels map[string]string
+
+// String returns the labels in the LogQL selector format, sorted by name
+func (l LogLabels) String() string {
+
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%s", name, strconv.Quote(l[name]))
+	}
+
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// LogEntry is a log line returned by loki
+type LogEntry struct {
+	Timestamp time.Time
+	Labels    LogLabels
+	Line      string
+}
+
+// key returns a unique identifier of the entry
+func (e LogEntry) key() string {
+	return fmt.Sprintf("%d|%s|%s", e.Timestamp.UnixNano(), e.Labels, e.Line)
+}
+
+// LogQuery is a loki log query over a time window
+type LogQuery struct {
+	Query     string
+	Start     time.Time
+	End       time.Time
+	Limit     int // 0 means no limit
+	Forward   bool
+	Follow    bool // only supported forward
+	Tail      int  // when following, start with the last entries of the window
+	BatchSize int
+
+	// OnStatus is called when following and the connection to loki is lost or recovered
+	OnStatus func(FollowStatus)
+}
+
+// FollowStatus is the state of a followed log query
+type FollowStatus struct {
+	Connected   bool
+	Err         error         // the error that caused the disconnection
+	RetryIn     time.Duration // the backoff before the next attempt
+	ResumeFrom  time.Time     // the timestamp the query resumes from
+	Disconnects int
+	Duplicates  int // the entries returned again by loki and dropped
+	Entries     int
+}
+
+// String returns the status line of the follow status
+func (s FollowStatus) String() string {
+
+	counters := fmt.Sprintf("%d entries, %d disconnects, %d duplicates dropped", s.Entries, s.Disconnects, s.Duplicates)
+
+	if !s.Connected {
+		return fmt.Sprintf("> Disconnected from loki: %s, retrying in %s (%s)", s.Err, s.RetryIn.Round(time.Second), counters)
+	}
+
+	return fmt.Sprintf("> Reconnected to loki, resumed from %s (%s)", s.ResumeFrom.Format(time.RFC3339Nano), counters)
+}
+
+// LokiClient queries a loki datasource through the monitoring proxy
+type LokiClient struct {
+	client Client
+	proxy  int
+}
+
+// Loki returns a client for the loki datasource behind the proxy
+func (m Client) Loki(proxy int) LokiClient {
+	return LokiClient{client: m, proxy: proxy}
+}
+
+// lokiResponse is the response of the loki query_range endpoint
+type lokiResponse struct {
+	Data struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Stream LogLabels   `json:"stream"`
+			Values []lokiValue `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// lokiValue is a [timestamp, line] pair of a loki stream
+type lokiValue struct {
+	Timestamp time.Time
+	Line      string
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Extra elements
+// such as structured metadata are ignored.
+func (v *lokiValue) UnmarshalJSON(data []byte) error {
+
+	raw := []json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if len(raw) < 2 {
+		return fmt.Errorf("invalid log value: %s", data)
+	}
+
+	var ts string
+	if err := json.Unmarshal(raw[0], &ts); err != nil {
+		return fmt.Errorf("invalid log timestamp: %w", err)
+	}
+
+	ns, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid log timestamp: %w", err)
+	}
+
+	v.Timestamp = time.Unix(0, ns)
+
+	return json.Unmarshal(raw[1], &v.Line)
+}
+
+// queryRange returns the entries of a single query_range call sorted in the query direction
+func (l LokiClient) queryRange(query string, start, end time.Time, limit int, forward bool) ([]LogEntry, error) {
+
+	direction := "backward"
+	if forward {
+		direction = "forward"
+	}
+
+	values := url.Values{}
+	values.Set("query", query)
+	values.Set("start", strconv.FormatInt(start.UnixNano(), 10))
+	values.Set("end", strconv.FormatInt(end.UnixNano(), 10))
+	values.Set("limit", strconv.Itoa(limit))
+	values.Set("direction", direction)
+
+	zap.L().Debug("Querying loki",
+		zap.String("query", query),
+		zap.Time("start", start),
+		zap.Time("end", end),
+		zap.Int("limit", limit),
+		zap.String("direction", direction),
+	)
+
+	res := &lokiResponse{}
+	if err := l.client.getJSON(l.proxy, "loki/api/v1/query_range", values, res); err != nil {
+		return nil, fmt.Errorf("unable to query logs: %w", err)
+	}
+
+	if res.Data.ResultType != "streams" {
+		return nil, fmt.Errorf("unable to query logs: expected streams, got %s", res.Data.ResultType)
+	}
+
+	entries := []LogEntry{}
+	for _, stream := range res.Data.Result {
+		for _, v := range stream.Values {
+			entries = append(entries, LogEntry{Timestamp: v.Timestamp, Labels: stream.Stream, Line: v.Line})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if forward {
+			return entries[i].Timestamp.Before(entries[j].Timestamp)
+		}
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+
+	return entries, nil
+}
+
+// Query returns an iterator over the entries matching the query.
+// The entries are fetched by batches as the iterator advances.
+func (l LokiClient) Query(q LogQuery) *LogIterator {
+
+	if q.BatchSize <= 0 {
+		q.BatchSize = defaultBatchSize
+	}
+
+	if q.Follow {
+		q.Forward = true
+	}
+
+	return &LogIterator{loki: l, query: q, seen: map[string]struct{}{}}
+}
+
+// LogIterator iterates over the entries of a log query.
+//
+//	it := loki.Query(q)
+//	for it.Next() {
+//		entry := it.Entry()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type LogIterator struct {
+	loki  LokiClient
+	query LogQuery
+
+	batch []LogEntry
+	entry LogEntry
+	count int
+	done  bool
+	err   error
+
+	// seen holds the entries at the boundary of the last batch as
+	// the next batch starts at the same timestamp.
+	seen     map[string]struct{}
+	boundary time.Time
+
+	// The state of the connection when following
+	status         FollowStatus
+	started        bool
+	backoff        time.Duration
+	disconnectedAt time.Time
+}
+
+// Next advances the iterator to the next entry. It returns false when there
+// are no more entries or on error. When following, it blocks until new
+// entries are available.
+func (it *LogIterator) Next() bool {
+
+	if it.query.Limit > 0 && it.count >= it.query.Limit {
+		return false
+	}
+
+	for len(it.batch) == 0 {
+
+		if it.err != nil || it.done {
+			return false
+		}
+
+		if it.err = it.fetch(); it.err != nil {
+
+			// An error on the first request is not a disconnection but
+			// most likely an invalid query, a rejected request is not retried
+			if !it.query.Follow || !it.started || !isDisconnection(it.err) || !it.reconnect() {
+				return false
+			}
+		}
+	}
+
+	it.entry, it.batch = it.batch[0], it.batch[1:]
+	it.count++
+
+	return true
+}
+
+// Entry returns the current entry
+func (it *LogIterator) Entry() LogEntry {
+	return it.entry
+}
+
+// Err returns the error that stopped the iteration if any
+func (it *LogIterator) Err() error {
+	return it.err
+}
+
+// fetch retrieves the next batch of entries
+func (it *LogIterator) fetch() error {
+
+	limit := it.query.BatchSize
+	if it.query.Limit > 0 && it.query.Limit-it.count < limit {
+		limit = it.query.Limit - it.count
+	}
+
+	// The entries at the boundary are returned again
+	limit += len(it.seen)
+
+	start, end := it.query.Start, it.query.End
+	if it.query.Follow {
+		end = time.Now()
+	}
+
+	// Resume from the last timestamp, the start is inclusive and the end exclusive
+	if !it.boundary.IsZero() {
+		if it.query.Forward {
+			start = it.boundary
+		} else {
+			end = it.boundary.Add(time.Nanosecond)
+		}
+	}
+
+	var entries []LogEntry
+	var err error
+
+	if it.query.Follow && it.query.Tail > 0 && !it.started {
+
+		// The last entries of the window are fetched backward, then
+		// the query is followed from the most recent one
+		if entries, err = it.loki.queryRange(it.query.Query, start, end, it.query.Tail, false); err != nil {
+			return err
+		}
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+
+	} else if entries, err = it.loki.queryRange(it.query.Query, start, end, limit, it.query.Forward); err != nil {
+		return err
+	}
+
+	fresh := it.dedupe(entries)
+
+	it.status.Duplicates += len(entries) - len(fresh)
+	it.status.Entries += len(fresh)
+
+	if it.query.Follow && !it.status.Connected {
+		it.status.Connected, it.status.Err, it.status.RetryIn = true, nil, 0
+		it.backoff, it.disconnectedAt = followBackoff, time.Time{}
+		// Only report the recovery from a disconnection
+		if it.started {
+			it.notify()
+		}
+	}
+	it.started = true
+
+	// A short batch means the window is exhausted. As a batch made only of
+	// duplicates would be fetched again, it is considered exhausted too.
+	if len(entries) < limit || len(fresh) == 0 {
+		if !it.query.Follow {
+			it.done = true
+		} else if len(fresh) == 0 {
+			time.Sleep(followInterval)
+		}
+	}
+
+	it.batch = fresh
+
+	return nil
+}
+
+// reconnect records the disconnection and waits before the next attempt.
+// The next attempt resumes from the last timestamp seen. It returns false,
+// keeping the last error, when loki is unreachable for followMaxDisconnect.
+func (it *LogIterator) reconnect() bool {
+
+	if it.status.Connected {
+		it.status.Disconnects++
+		it.disconnectedAt = time.Now()
+	}
+
+	if time.Since(it.disconnectedAt)+it.backoff > followMaxDisconnect {
+		it.err = fmt.Errorf("unable to reconnect to loki for %s: %w", followMaxDisconnect, it.err)
+		return false
+	}
+
+	it.status.Connected = false
+	it.status.Err = it.err
+	it.status.RetryIn = it.backoff
+	it.status.ResumeFrom = it.boundary
+	if it.boundary.IsZero() {
+		it.status.ResumeFrom = it.query.Start
+	}
+	it.notify()
+
+	zap.L().Debug("Lost connection to loki", zap.Error(it.err), zap.Duration("backoff", it.backoff))
+
+	time.Sleep(it.backoff + time.Duration(rand.Int63n(int64(it.backoff/2)+1)))
+
+	it.backoff *= 2
+	if it.backoff > followMaxBackoff {
+		it.backoff = followMaxBackoff
+	}
+
+	it.err = nil
+
+	return true
+}
+
+// notify reports the follow status if requested
+func (it *LogIterator) notify() {
+
+	if it.query.OnStatus != nil {
+		it.query.OnStatus(it.status)
+	}
+}
+
+// dedupe removes the entries already returned by the previous batch and
+// records the entries at the boundary of this one
+func (it *LogIterator) dedupe(entries []LogEntry) []LogEntry {
+
+	fresh := make([]LogEntry, 0, len(entries))
+	for _, e := range entries {
+		if _, ok := it.seen[e.key()]; !ok {
+			fresh = append(fresh, e)
+		}
+	}
+
+	if len(entries) == 0 {
+		return fresh
+	}
+
+	last := entries[len(entries)-1].Timestamp
+	if !last.Equal(it.boundary) {
+		it.boundary = last
+		it.seen = map[string]struct{}{}
+	}
+
+	for _, e := range entries {
+		if e.Timestamp.Equal(last) {
+			it.seen[e.key()] = struct{}{}
+		}
+	}
+
+	return fresh
+}
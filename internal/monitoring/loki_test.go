@@ -0,0 +1,457 @@
+package monitoring
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeLoki is a fake loki query_range endpoint serving the given entries
+func fakeLoki(t *testing.T, entries []LogEntry, requests *int) *httptest.Server {
+	return fakeFlakyLoki(t, entries, requests, nil)
+}
+
+// fakeFlakyLoki is a fakeLoki failing the requests with the given numbers
+// with the given status code, or dropping the connection if it is 0
+func fakeFlakyLoki(t *testing.T, entries []LogEntry, requests *int, failures map[int]int) *httptest.Server {
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if !strings.HasSuffix(r.URL.Path, "/loki/api/v1/query_range") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		*requests++
+
+		if code, ok := failures[*requests]; ok {
+			if code == 0 {
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close() // nolint
+				return
+			}
+			w.WriteHeader(code)
+			return
+		}
+
+		q := r.URL.Query()
+		if q.Get("query") == "bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "parse error at line 1, col 1: syntax error") // nolint
+			return
+		}
+
+		start, _ := strconv.ParseInt(q.Get("start"), 10, 64)
+		end, _ := strconv.ParseInt(q.Get("end"), 10, 64)
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		forward := q.Get("direction") == "forward"
+
+		// Only the pod matcher is supported
+		pod := regexp.MustCompile(`pod="([^"]+)"`).FindStringSubmatch(q.Get("query"))
+
+		matching := []LogEntry{}
+		for _, e := range entries {
+			if pod != nil && e.Labels["pod"] != pod[1] {
+				continue
+			}
+			if ns := e.Timestamp.UnixNano(); ns >= start && ns < end {
+				matching = append(matching, e)
+			}
+		}
+
+		sort.SliceStable(matching, func(i, j int) bool {
+			if forward {
+				return matching[i].Timestamp.Before(matching[j].Timestamp)
+			}
+			return matching[i].Timestamp.After(matching[j].Timestamp)
+		})
+
+		if len(matching) > limit {
+			matching = matching[:limit]
+		}
+
+		// One stream per label set
+		streams := map[string]map[string]any{}
+		result := []map[string]any{}
+		for _, e := range matching {
+			s, ok := streams[e.Labels.String()]
+			if !ok {
+				s = map[string]any{"stream": e.Labels, "values": [][]string{}}
+				streams[e.Labels.String()] = s
+				result = append(result, s)
+			}
+			s["values"] = append(s["values"].([][]string), []string{strconv.FormatInt(e.Timestamp.UnixNano(), 10), e.Line})
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{ // nolint
+			"status": "success",
+			"data":   map[string]any{"resultType": "streams", "result": result},
+		})
+	}))
+
+	// A dropped connection would be retried by the transport if it was reused
+	server.Config.SetKeepAlivesEnabled(false)
+	server.Start()
+
+	return server
+}
+
+func TestLokiClient_Query(t *testing.T) {
+
+	base := time.Unix(1700000000, 0)
+	squall := LogLabels{"app": "squall", "pod": "squall-1"}
+	cid := LogLabels{"app": "cid", "pod": "cid-1"}
+
+	entries := []LogEntry{
+		{Timestamp: base, Labels: squall, Line: "l0"},
+		{Timestamp: base.Add(1 * time.Second), Labels: cid, Line: "l1"},
+		{Timestamp: base.Add(2 * time.Second), Labels: squall, Line: "l2"},
+		// Same timestamp across a batch boundary
+		{Timestamp: base.Add(3 * time.Second), Labels: squall, Line: "l3"},
+		{Timestamp: base.Add(3 * time.Second), Labels: cid, Line: "l4"},
+		{Timestamp: base.Add(4 * time.Second), Labels: cid, Line: "l5"},
+		{Timestamp: base.Add(5 * time.Second), Labels: squall, Line: "l6"},
+	}
+
+	tests := []struct {
+		name      string
+		query     LogQuery
+		want      []string
+		wantErr   string
+		wantCalls int
+	}{
+		{
+			"forward in batches",
+			LogQuery{Query: "{app=~\".+\"}", Start: base, End: base.Add(time.Minute), Forward: true, BatchSize: 2},
+			[]string{"l0", "l1", "l2", "l3", "l4", "l5", "l6"},
+			"",
+			4,
+		},
+		{
+			"backward in batches",
+			LogQuery{Query: "{app=~\".+\"}", Start: base, End: base.Add(time.Minute), BatchSize: 3},
+			[]string{"l6", "l5", "l3", "l4", "l2", "l1", "l0"},
+			"",
+			3,
+		},
+		{
+			"limit",
+			LogQuery{Query: "{app=~\".+\"}", Start: base, End: base.Add(time.Minute), Forward: true, Limit: 3, BatchSize: 2},
+			[]string{"l0", "l1", "l2"},
+			"",
+			2,
+		},
+		{
+			"window",
+			LogQuery{Query: "{app=~\".+\"}", Start: base.Add(time.Second), End: base.Add(3 * time.Second), Forward: true},
+			[]string{"l1", "l2"},
+			"",
+			1,
+		},
+		{
+			"error",
+			LogQuery{Query: "bad", Start: base, End: base.Add(time.Minute)},
+			nil,
+			"unable to query logs: return code 400: parse error at line 1, col 1: syntax error",
+			1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			calls := 0
+			server := fakeLoki(t, entries, &calls)
+			defer server.Close()
+
+			u, _ := url.Parse(server.URL)
+			m := Client{url: u, limiters: map[int]*rateLimiter{}}
+
+			got := []string{}
+			it := m.Loki(1).Query(tt.query)
+			for it.Next() {
+				got = append(got, it.Entry().Line)
+			}
+
+			if err := it.Err(); (err != nil || tt.wantErr != "") && fmt.Sprint(err) != tt.wantErr {
+				t.Fatalf("Err() = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.want != nil && strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("entries = %v, want %v", got, tt.want)
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestLokiClient_QueryFollow(t *testing.T) {
+
+	interval, backoff, maxBackoff, maxDisconnect := followInterval, followBackoff, followMaxBackoff, followMaxDisconnect
+	t.Cleanup(func() {
+		followInterval, followBackoff, followMaxBackoff, followMaxDisconnect = interval, backoff, maxBackoff, maxDisconnect
+	})
+
+	followInterval, followBackoff, followMaxBackoff, followMaxDisconnect = time.Millisecond, time.Millisecond, 4*time.Millisecond, 50*time.Millisecond
+
+	base := time.Unix(1700000000, 0)
+	labels := LogLabels{"app": "squall"}
+
+	entries := []LogEntry{}
+	for i := 0; i < 6; i++ {
+		entries = append(entries, LogEntry{Timestamp: base.Add(time.Duration(i/2) * time.Second), Labels: labels, Line: fmt.Sprintf("l%d", i)})
+	}
+
+	t.Run("reconnect", func(t *testing.T) {
+
+		calls := 0
+		server := fakeFlakyLoki(t, entries, &calls, map[int]int{2: 0, 3: 0, 5: 0})
+		defer server.Close()
+
+		u, _ := url.Parse(server.URL)
+		m := Client{url: u, limiters: map[int]*rateLimiter{}}
+
+		statuses := []FollowStatus{}
+		it := m.Loki(1).Query(LogQuery{
+			Query:     "{app=\"squall\"}",
+			Start:     base,
+			Follow:    true,
+			Limit:     len(entries),
+			BatchSize: 2,
+			OnStatus:  func(s FollowStatus) { statuses = append(statuses, s) },
+		})
+
+		got := []string{}
+		for it.Next() {
+			got = append(got, it.Entry().Line)
+		}
+
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Join(got, ",") != "l0,l1,l2,l3,l4,l5" {
+			t.Errorf("entries = %v", got)
+		}
+
+		// Two disconnections, the first one with two failed attempts
+		connected := []bool{}
+		for _, s := range statuses {
+			connected = append(connected, s.Connected)
+		}
+		if fmt.Sprint(connected) != "[false false true false true]" {
+			t.Fatalf("statuses = %+v", statuses)
+		}
+
+		last := statuses[len(statuses)-1]
+		if last.Disconnects != 2 || !last.ResumeFrom.Equal(base.Add(time.Second)) {
+			t.Errorf("last status = %+v", last)
+		}
+
+		if s := last.String(); !strings.HasPrefix(s, "> Reconnected to loki, resumed from ") || !strings.HasSuffix(s, "(6 entries, 2 disconnects, 4 duplicates dropped)") {
+			t.Errorf("String() = %s", s)
+		}
+	})
+
+	t.Run("tail", func(t *testing.T) {
+
+		calls := 0
+		server := fakeFlakyLoki(t, entries, &calls, nil)
+		defer server.Close()
+
+		u, _ := url.Parse(server.URL)
+		m := Client{url: u, limiters: map[int]*rateLimiter{}}
+
+		it := m.Loki(1).Query(LogQuery{Query: "{app=\"squall\"}", Start: base, Follow: true, Tail: 4, Limit: 4})
+
+		got := []string{}
+		for it.Next() {
+			got = append(got, it.Entry().Line)
+		}
+
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+
+		// The entries of the same timestamp are not ordered by the fake loki
+		sort.Strings(got)
+		if strings.Join(got, ",") != "l2,l3,l4,l5" || calls != 1 {
+			t.Errorf("entries = %v in %d calls", got, calls)
+		}
+	})
+
+	t.Run("give up", func(t *testing.T) {
+
+		// Every request after the first one fails
+		failures := map[int]int{}
+		for i := 2; i < 1000; i++ {
+			failures[i] = 0
+		}
+
+		calls := 0
+		server := fakeFlakyLoki(t, entries, &calls, failures)
+		defer server.Close()
+
+		u, _ := url.Parse(server.URL)
+		m := Client{url: u, limiters: map[int]*rateLimiter{}}
+
+		disconnects := 0
+		it := m.Loki(1).Query(LogQuery{
+			Query:     "{app=\"squall\"}",
+			Start:     base,
+			Follow:    true,
+			BatchSize: 2,
+			OnStatus:  func(s FollowStatus) { disconnects = s.Disconnects },
+		})
+
+		got := []string{}
+		for it.Next() {
+			got = append(got, it.Entry().Line)
+		}
+
+		if strings.Join(got, ",") != "l0,l1" {
+			t.Errorf("entries = %v", got)
+		}
+
+		if err := it.Err(); err == nil || !strings.HasPrefix(err.Error(), "unable to reconnect to loki for 50ms: ") {
+			t.Errorf("Err() = %v", err)
+		}
+
+		if disconnects != 1 || calls < 3 {
+			t.Errorf("disconnects = %d, calls = %d", disconnects, calls)
+		}
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+
+		calls := 0
+		server := fakeFlakyLoki(t, entries, &calls, map[int]int{1: 0})
+		defer server.Close()
+
+		u, _ := url.Parse(server.URL)
+		m := Client{url: u, limiters: map[int]*rateLimiter{}}
+
+		it := m.Loki(1).Query(LogQuery{Query: "{app=\"squall\"}", Start: base, Follow: true})
+		if it.Next() || it.Err() == nil {
+			t.Fatalf("Next() should fail on the first request")
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+
+		calls := 0
+		server := fakeFlakyLoki(t, entries, &calls, map[int]int{2: http.StatusForbidden})
+		defer server.Close()
+
+		u, _ := url.Parse(server.URL)
+		m := Client{url: u, limiters: map[int]*rateLimiter{}}
+
+		disconnects := 0
+		it := m.Loki(1).Query(LogQuery{
+			Query:     "{app=\"squall\"}",
+			Start:     base,
+			Follow:    true,
+			BatchSize: 2,
+			OnStatus:  func(s FollowStatus) { disconnects = s.Disconnects },
+		})
+
+		got := []string{}
+		for it.Next() {
+			got = append(got, it.Entry().Line)
+		}
+
+		if strings.Join(got, ",") != "l0,l1" {
+			t.Errorf("entries = %v", got)
+		}
+
+		if err := it.Err(); err == nil || err.Error() != "unable to query logs: return code 403" {
+			t.Errorf("Err() = %v", err)
+		}
+
+		if disconnects != 0 || calls != 2 {
+			t.Errorf("disconnects = %d, calls = %d", disconnects, calls)
+		}
+	})
+}
+
+func TestLogPrinter(t *testing.T) {
+
+	e := LogEntry{
+		Timestamp: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC),
+		Labels:    LogLabels{"app": "squall", "pod": "squall-1", "filename": "/var/log/squall.log"},
+		Line:      "hello \"world\"\n",
+	}
+
+	tests := []struct {
+		name     string
+		format   string
+		noLabels bool
+		colored  bool
+		want     string
+	}{
+		{"default", LogOutputDefault, false, false, "2023-11-14T22:13:20Z {pod=\"squall-1\"} hello \"world\"\n"},
+		{"default no labels", LogOutputDefault, true, true, "2023-11-14T22:13:20Z hello \"world\"\n"},
+		{"default colored", LogOutputDefault, false, true, "2023-11-14T22:13:20Z \x1b["},
+		{"raw", LogOutputRaw, false, true, "hello \"world\"\n"},
+		{"jsonl", LogOutputJSONL, false, true, `{"timestamp":"2023-11-14T22:13:20Z","labels":{"app":"squall","filename":"/var/log/squall.log","pod":"squall-1"},"line":"hello \"world\""}` + "\n"},
+		{"default zap", LogOutputDefault, false, false, "2023-11-14T22:13:20Z {pod=\"squall-1\"} ERROR boom error=\"no mongo\"\n"},
+		{"raw zap", LogOutputRaw, false, false, `{"level":"error","ts":1700000000,"msg":"boom","error":"no mongo"}` + "\n"},
+		{"logfmt", LogOutputLogfmt, false, true, `ts=2023-11-14T22:13:20Z app=squall filename=/var/log/squall.log pod=squall-1 line="hello \"world\""` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := e
+			if strings.HasSuffix(tt.name, "zap") {
+				entry.Line = `{"level":"error","ts":1700000000,"msg":"boom","error":"no mongo"}`
+			}
+
+			buf := &bytes.Buffer{}
+			if err := NewLogPrinter(buf, tt.format, tt.noLabels, tt.colored).Print(entry); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(buf.String(), tt.want) {
+				t.Errorf("Print() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestUseColors(t *testing.T) {
+
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() // nolint
+
+	tests := []struct {
+		mode string
+		want bool
+	}{
+		{"always", true},
+		{"never", false},
+		{"auto", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			if got := useColors(tt.mode, f); got != tt.want {
+				t.Errorf("useColors() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"hash/fnv"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -23,21 +24,44 @@ type APIErrors []APIError
 
 // APIError repesent an API error
 type APIError struct {
-	Service   string
-	Identity  string
-	Operation string
-	Method    string
-	URL       string
-	Traces    []string
-	Code      int
-	Count     int
+	Service    string
+	Identity   string
+	Operation  string
+	Method     string
+	URL        string
+	Traces     []string
+	Code       int
+	Count      int
+	Source     CountSource
+	Anomaly    *Anomaly
+	Latency    *LatencyStats
+	Errors     *ErrorSummary
+	Warnings   []string
+	TraceError error
+}
+
+// CountSource represents where the count of an APIError comes from
+type CountSource string
+
+const (
+	// CountSourceMetrics is an exact count computed from the metrics
+	CountSourceMetrics CountSource = "metrics"
+
+	// CountSourceTraces is a count sampled from the traces found
+	CountSourceTraces CountSource = "traces"
+)
+
+// key returns the aggregation key of an error based
+// on service, code, method and url template
+func (a APIError) key() string {
+	return fmt.Sprintf("%s|%d|%s|%s", a.Service, a.Code, a.Method, a.URL)
 }
 
 // Hash return a unique identifier for an error
-// based on url code and operation
+// based on its aggregation key
 func (a APIError) Hash() uint32 {
 	h := fnv.New32a()
-	h.Write([]byte(fmt.Sprintf("%d", a.Code) + a.URL + a.Method)) // nolint
+	h.Write([]byte(a.key())) // nolint
 	return h.Sum32()
 }
 
@@ -48,16 +72,33 @@ func (a ByCount) Len() int           { return len(a) }
 func (a ByCount) Less(i, j int) bool { return a[i].Count < a[j].Count }
 func (a ByCount) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 
-// GetAPIErrors retrieve the errors metrics from prometheus as APiErrors
-func (m Client) GetAPIErrors(proxy int, since time.Duration, at time.Time) (APIErrors, error) {
+// GetAPIErrors retrieve the errors metrics from prometheus as APiErrors.
+// If a namespace is given, the metrics are restricted to it (and its children if recursive).
+func (m Client) GetAPIErrors(proxy int, since time.Duration, at time.Time, namespace string, recursive bool) (APIErrors, error) {
+	return m.getAPIErrors(proxy, since, 0, at, namespace, recursive)
+}
+
+// getAPIErrors retrieve the errors metrics over the window shifted in the past by offset
+func (m Client) getAPIErrors(proxy int, since time.Duration, offset time.Duration, at time.Time, namespace string, recursive bool) (APIErrors, error) {
+
+	matcher := ""
+	if namespace != "" {
+		matcher = "," + m.namespaceMatcher(namespace, recursive)
+	}
+
+	shift := ""
+	if offset > 0 {
+		shift = fmt.Sprintf(" offset %ds", int(offset.Seconds()))
+	}
+
 	// query the errors
-	errRes, err := m.queryPrometheus(proxy, fmt.Sprintf("sum(delta(http_requests_total{code!~'0|500'}[%ds])) by (service,code,method,url) >0", int(since.Seconds())), at)
+	errRes, err := m.queryPrometheus(proxy, fmt.Sprintf("sum(delta(http_requests_total{code!~'0|500'%s}[%ds]%s)) by (service,code,method,url) >0", matcher, int(since.Seconds()), shift), at)
 	if err != nil {
 		return nil, err
 	}
 
 	// query the 500
-	panicRes, err := m.queryPrometheus(proxy, fmt.Sprintf("count((http_errors_5xx_total{code='500'} > 0 unless http_errors_5xx_total{code='500'} offset %ds) or ((http_errors_5xx_total{code='500'} - http_errors_5xx_total{code='500'} offset %ds) >0)) by (service,code,method,url) >0", int(since.Seconds()), int(since.Seconds())), at)
+	panicRes, err := m.queryPrometheus(proxy, fmt.Sprintf("count((http_errors_5xx_total{code='500'%[1]s}%[2]s > 0 unless http_errors_5xx_total{code='500'%[1]s} offset %[3]ds) or ((http_errors_5xx_total{code='500'%[1]s}%[2]s - http_errors_5xx_total{code='500'%[1]s} offset %[3]ds) >0)) by (service,code,method,url) >0", matcher, shift, int((since+offset).Seconds())), at)
 	if err != nil {
 		return nil, err
 	}
@@ -65,18 +106,71 @@ func (m Client) GetAPIErrors(proxy int, since time.Duration, at time.Time) (APIE
 	return append(errRes, panicRes...), nil
 }
 
+// namespacedMetrics are the metrics the namespace matcher is applied to
+var namespacedMetrics = []string{"http_requests_total", "http_errors_5xx_total"}
+
+// SupportsNamespace checks if all the metrics of the errors carry
+// the namespace label with Aporeto namespaces as values.
+func (m Client) SupportsNamespace(proxy int, at time.Time) (bool, error) {
+
+	for _, metric := range namespacedMetrics {
+
+		result, err := m.promQuery(proxy, fmt.Sprintf("count(%s{%s=~'/.*'})", metric, m.cfg.MetricsNamespaceLabel), at)
+		if err != nil {
+			return false, err
+		}
+
+		vector, ok := result.(model.Vector)
+		if !ok {
+			return false, fmt.Errorf("unexpected prometheus result type: %s", result.Type())
+		}
+
+		if len(vector) == 0 || vector[0].Value <= 0 {
+			zap.L().Debug("Metric without namespace label", zap.String("metric", metric), zap.String("label", m.cfg.MetricsNamespaceLabel))
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// namespaceMatcher returns the PromQL label matcher for a namespace
+func (m Client) namespaceMatcher(namespace string, recursive bool) string {
+
+	if recursive {
+		return fmt.Sprintf("%s=~'%s'", m.cfg.MetricsNamespaceLabel, escapePromQL(regexp.QuoteMeta(namespace)+"(/.*)?"))
+	}
+
+	return fmt.Sprintf("%s='%s'", m.cfg.MetricsNamespaceLabel, escapePromQL(namespace))
+}
+
+// escapePromQL escapes a value to be used in a single quoted PromQL string
+func escapePromQL(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+}
+
 func (m Client) queryPrometheus(proxy int, query string, at time.Time) (APIErrors, error) {
-	promProxy, err := url.Parse(fmt.Sprintf("api/datasources/proxy/%d", proxy))
+
+	result, err := m.promQuery(proxy, query, at)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	client, err := api.NewClient(api.Config{Address: m.url.ResolveReference(promProxy).String(), RoundTripper: m.client.Transport})
+	results := parseMetrics(result)
+
+	zap.L().Debug("Quering prometheus", zap.String("query", query), zap.Int("results", len(results)))
+
+	return results, nil
+}
+
+// promQuery runs an instant query on prometheus and returns the raw result
+func (m Client) promQuery(proxy int, query string, at time.Time) (model.Value, error) {
+
+	v1api, err := m.promAPI(proxy)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create new prometheus client: %w", err)
+		return nil, err
 	}
 
-	v1api := v1.NewAPI(client)
 	tctx, tcancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer tcancel()
 
@@ -88,15 +182,28 @@ func (m Client) queryPrometheus(proxy int, query string, at time.Time) (APIError
 	if len(warnings) > 0 {
 		zap.L().Warn("Warning while querying Prometheus", zap.Strings("warnings", warnings))
 	}
-	results := parseMetrics(result)
 
-	zap.L().Debug("Quering prometheus", zap.String("query", query), zap.Int("results", len(results)))
+	return result, nil
+}
 
-	return results, nil
+// promAPI returns a prometheus client for the datasource behind the proxy
+func (m Client) promAPI(proxy int) (v1.API, error) {
+
+	promProxy, err := url.Parse(fmt.Sprintf("api/datasources/proxy/%d", proxy))
+	if err != nil {
+		panic(err)
+	}
+
+	client, err := api.NewClient(api.Config{Address: m.url.ResolveReference(promProxy).String(), RoundTripper: m.client.Transport})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create new prometheus client: %w", err)
+	}
+
+	return v1.NewAPI(client), nil
 }
 
-func parseMetrics(result model.Value) []APIError {
-	res := []APIError{}
+func parseMetrics(result model.Value) APIErrors {
+	res := APIErrors{}
 
 	keys := []model.LabelName{"code", "method", "url", "service"}
 
@@ -121,9 +228,11 @@ func parseMetrics(result model.Value) []APIError {
 			continue
 		}
 
-		identity, operation, err := extractIdentityFrom(string(v.Metric["url"]), string(v.Metric["method"]))
+		// Health checks, metrics and other non gaia routes are normal
+		// traffic, they are kept with their url unchanged
+		template, identity, operation, err := templateURL(string(v.Metric["url"]), string(v.Metric["method"]))
 		if err != nil {
-			zap.L().Error("Unable extract identity from url", zap.Error(err))
+			zap.L().Debug("Unable extract identity from url", zap.Error(err))
 		}
 
 		res = append(res, APIError{
@@ -132,41 +241,70 @@ func parseMetrics(result model.Value) []APIError {
 			Operation: string(operation),
 			Service:   string(v.Metric["service"]),
 			Method:    string(v.Metric["method"]),
-			URL:       string(v.Metric["url"]),
+			URL:       template,
 			Count:     int(v.Value),
+			Source:    CountSourceMetrics,
 		})
 	}
 
-	return res
+	return mergeAPIErrors(res)
 }
 
-// extractIdentityFrom extract Identity and Operation from url and method
-func extractIdentityFrom(url, method string) (identity elemental.Identity, operation elemental.Operation, err error) {
+// templateURL normalizes an url into a template where the object IDs
+// are replaced by :id (ex: /namespaces/:id/processingunits) and extracts
+// the targeted Identity and Operation from the template and the method.
+func templateURL(url, method string) (template string, identity elemental.Identity, operation elemental.Operation, err error) {
+
 	manager := gaia.Manager()
 
-	components := strings.Split(url, "/")
+	// Strip the query string if any, the url is returned unchanged on errors
+	path := url
+	if i := strings.Index(path, "?"); i != -1 {
+		path = path[:i]
+	}
+
+	components := []string{}
+	for _, c := range strings.Split(path, "/") {
+		if c != "" {
+			components = append(components, c)
+		}
+	}
+
+	if len(components) == 0 {
+		return url, identity, operation, fmt.Errorf("unable to decode url parts: empty url %s", url)
+	}
+
+	// The url alternates between categories and IDs: /category/id/category/id...
+	// so we walk it and resolve each category to the identity of its child.
+	for i, c := range components {
+
+		if i%2 == 1 {
+			components[i] = ":id"
+			continue
+		}
 
-	// We remove the first element as it's always empty
-	components = append(components[:0], components[1:]...)
+		child := manager.IdentityFromCategory(c)
+		if child.IsEmpty() {
+			return url, elemental.Identity{}, operation, fmt.Errorf("unable to decode url parts: unknown category %s in %s", c, url)
+		}
 
-	switch len(components) {
-	case 1, 2:
-		identity = manager.IdentityFromCategory(components[0])
-	case 3:
-		identity = manager.IdentityFromCategory(components[2])
-	default:
-		return identity, operation, fmt.Errorf("unable to decode url parts")
+		identity = child
 	}
 
+	template = "/" + strings.Join(components, "/")
+
+	// If the url ends with an ID we target an object, otherwise a collection
+	onObject := len(components)%2 == 0
+
 	switch method {
 	case http.MethodDelete:
 		operation = elemental.OperationDelete
 
 	case http.MethodGet:
-		if len(components) == 1 || len(components) == 3 {
-			operation = elemental.OperationRetrieveMany
-		} else {
+		if onObject {
 			operation = elemental.OperationRetrieve
+		} else {
+			operation = elemental.OperationRetrieveMany
 		}
 
 	case http.MethodHead:
@@ -180,8 +318,30 @@ func extractIdentityFrom(url, method string) (identity elemental.Identity, opera
 
 	case http.MethodPut:
 		operation = elemental.OperationUpdate
+	}
+
+	return template, identity, operation, nil
+}
+
+// mergeAPIErrors merges the APIErrors sharing the same service, code, method
+// and url template by summing their counts.
+func mergeAPIErrors(errs APIErrors) APIErrors {
+
+	res := APIErrors{}
+	index := make(map[string]int)
+
+	for _, e := range errs {
 
+		key := e.key()
+
+		if i, ok := index[key]; ok {
+			res[i].Count += e.Count
+			continue
+		}
+
+		index[key] = len(res)
+		res = append(res, e)
 	}
 
-	return identity, operation, nil
+	return res
 }
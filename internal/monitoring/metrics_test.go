@@ -0,0 +1,222 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aporeto-inc/tracer/internal/profiles"
+
+	"go.aporeto.io/elemental"
+	"go.aporeto.io/gaia"
+)
+
+func TestTemplateURL(t *testing.T) {
+	type args struct {
+		url    string
+		method string
+	}
+	tests := []struct {
+		name          string
+		args          args
+		wantTemplate  string
+		wantIdentity  elemental.Identity
+		wantOperation elemental.Operation
+		wantErr       bool
+	}{
+		{
+			"empty url",
+			args{
+				url:    "/",
+				method: http.MethodGet,
+			},
+			"/",
+			elemental.Identity{},
+			elemental.OperationEmpty,
+			true,
+		},
+		{
+			"unknown category",
+			args{
+				url:    "/chiens/5f1b0c1e4e3e8e0001a1b2c3",
+				method: http.MethodGet,
+			},
+			"/chiens/5f1b0c1e4e3e8e0001a1b2c3",
+			elemental.Identity{},
+			elemental.OperationEmpty,
+			true,
+		},
+		{
+			"non gaia route",
+			args{
+				url:    "/health?verbose=true",
+				method: http.MethodGet,
+			},
+			"/health?verbose=true",
+			elemental.Identity{},
+			elemental.OperationEmpty,
+			true,
+		},
+		{
+			"retrieve many",
+			args{
+				url:    "/processingunits",
+				method: http.MethodGet,
+			},
+			"/processingunits",
+			gaia.ProcessingUnitIdentity,
+			elemental.OperationRetrieveMany,
+			false,
+		},
+		{
+			"retrieve with query string",
+			args{
+				url:    "/processingunits/5f1b0c1e4e3e8e0001a1b2c3?recursive=true",
+				method: http.MethodGet,
+			},
+			"/processingunits/:id",
+			gaia.ProcessingUnitIdentity,
+			elemental.OperationRetrieve,
+			false,
+		},
+		{
+			"already templated",
+			args{
+				url:    "/enforcers/:id/poke",
+				method: http.MethodGet,
+			},
+			"/enforcers/:id/poke",
+			gaia.PokeIdentity,
+			elemental.OperationRetrieveMany,
+			false,
+		},
+		{
+			"nested collection",
+			args{
+				url:    "/namespaces/5f1b0c1e4e3e8e0001a1b2c3/processingunits",
+				method: http.MethodHead,
+			},
+			"/namespaces/:id/processingunits",
+			gaia.ProcessingUnitIdentity,
+			elemental.OperationInfo,
+			false,
+		},
+		{
+			"nested object",
+			args{
+				url:    "/namespaces/5f1b0c1e4e3e8e0001a1b2c3/processingunits/5f1b0c1e4e3e8e0001a1b2c4/",
+				method: http.MethodDelete,
+			},
+			"/namespaces/:id/processingunits/:id",
+			gaia.ProcessingUnitIdentity,
+			elemental.OperationDelete,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTemplate, gotIdentity, gotOperation, err := templateURL(tt.args.url, tt.args.method)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("templateURL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotTemplate != tt.wantTemplate {
+				t.Errorf("templateURL() gotTemplate = %v, want %v", gotTemplate, tt.wantTemplate)
+			}
+			if !reflect.DeepEqual(gotIdentity, tt.wantIdentity) {
+				t.Errorf("templateURL() gotIdentity = %v, want %v", gotIdentity, tt.wantIdentity)
+			}
+			if gotOperation != tt.wantOperation {
+				t.Errorf("templateURL() gotOperation = %v, want %v", gotOperation, tt.wantOperation)
+			}
+		})
+	}
+}
+
+func TestMergeAPIErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		errs APIErrors
+		want APIErrors
+	}{
+		{
+			"merge same template",
+			APIErrors{
+				APIError{Service: "squall", Code: 200, Method: http.MethodGet, URL: "/processingunits/:id", Count: 1},
+				APIError{Service: "squall", Code: 403, Method: http.MethodGet, URL: "/processingunits/:id", Count: 2},
+				APIError{Service: "squall", Code: 200, Method: http.MethodGet, URL: "/processingunits/:id", Count: 3},
+				APIError{Service: "gaga", Code: 200, Method: http.MethodGet, URL: "/processingunits/:id", Count: 4},
+			},
+			APIErrors{
+				APIError{Service: "squall", Code: 200, Method: http.MethodGet, URL: "/processingunits/:id", Count: 4},
+				APIError{Service: "squall", Code: 403, Method: http.MethodGet, URL: "/processingunits/:id", Count: 2},
+				APIError{Service: "gaga", Code: 200, Method: http.MethodGet, URL: "/processingunits/:id", Count: 4},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeAPIErrors(tt.errs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeAPIErrors() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_SupportsNamespace(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		labelled []string
+		want     bool
+	}{
+		{"all metrics", []string{"http_requests_total", "http_errors_5xx_total"}, true},
+		{"requests only", []string{"http_requests_total"}, false},
+		{"none", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+				if r.URL.Path != "/api/datasources/proxy/1/api/v1/query" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				r.ParseForm() // nolint
+				query := r.Form.Get("query")
+				if !strings.Contains(query, "{ns=~'/.*'}") {
+					t.Errorf("query = %s", query)
+				}
+
+				result := []any{}
+				for _, metric := range tt.labelled {
+					if strings.HasPrefix(query, "count("+metric+"{") {
+						result = append(result, map[string]any{"metric": map[string]string{}, "value": []any{1700000000, "12"}})
+					}
+				}
+
+				json.NewEncoder(w).Encode(map[string]any{"status": "success", "data": map[string]any{"resultType": "vector", "result": result}}) // nolint
+			}))
+			defer server.Close()
+
+			u, _ := url.Parse(server.URL)
+			m := Client{url: u, cfg: &profiles.Datasource{MetricsNamespaceLabel: "ns"}, client: http.Client{Transport: http.DefaultTransport}}
+
+			got, err := m.SupportsNamespace(1, time.Unix(1700000000, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("SupportsNamespace() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -15,9 +15,13 @@ import (
 
 // Client is a monitoring client that can query the monitoring stacks
 type Client struct {
-	url    *url.URL
-	cfg    *profiles.Datasource
-	client http.Client
+	url      *url.URL
+	cfg      *profiles.Datasource
+	client   http.Client
+	limiters map[int]*rateLimiter
+
+	// concurrency is the number of concurrent trace lookups of a search
+	concurrency int
 }
 
 // NewClient return a new montitoring.Client
@@ -69,5 +73,6 @@ func NewClient(cfg *profiles.Datasource) (*Client, error) {
 			},
 		},
 		url: url, cfg: cfg,
+		limiters: map[int]*rateLimiter{},
 	}, nil
 }
@@ -0,0 +1,226 @@
+package monitoring
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// otlpTraces is an OTLP JSON trace. Tempo returns
+// the resource spans as batches on its v1 API.
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans,omitempty"`
+	Batches       []otlpResourceSpans `json:"batches,omitempty"`
+}
+
+// otlpResourceSpans are the spans emitted by a resource
+type otlpResourceSpans struct {
+	Resource                    otlpResource     `json:"resource"`
+	ScopeSpans                  []otlpScopeSpans `json:"scopeSpans,omitempty"`
+	InstrumentationLibrarySpans []otlpScopeSpans `json:"instrumentationLibrarySpans,omitempty"`
+}
+
+// otlpResource is the resource emitting spans
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+// otlpScopeSpans are the spans of an instrumentation scope
+type otlpScopeSpans struct {
+	Spans []otlpSpan `json:"spans"`
+}
+
+// otlpSpan is an OTLP span
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              any            `json:"kind,omitempty"`
+	StartTimeUnixNano otlpUint64     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   otlpUint64     `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+// otlpEvent is an event that happened during a span
+type otlpEvent struct {
+	TimeUnixNano otlpUint64     `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+// otlpStatus is the status of a span, the code
+// can be encoded as an integer or as an enum name
+type otlpStatus struct {
+	Code    any    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// otlpKeyValue is an OTLP attribute
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+// otlpAnyValue is an OTLP attribute value
+type otlpAnyValue struct {
+	StringValue *string     `json:"stringValue,omitempty"`
+	BoolValue   *bool       `json:"boolValue,omitempty"`
+	IntValue    *otlpUint64 `json:"intValue,omitempty"`
+	DoubleValue *float64    `json:"doubleValue,omitempty"`
+}
+
+// otlpUint64 is an integer encoded either as a
+// JSON number or as a JSON string as in proto3
+type otlpUint64 int64
+
+// MarshalJSON implements the json.Marshaler interface
+func (o otlpUint64) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatInt(int64(o), 10))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (o *otlpUint64) UnmarshalJSON(data []byte) error {
+
+	v, err := strconv.ParseInt(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer %s: %w", data, err)
+	}
+
+	*o = otlpUint64(v)
+	return nil
+}
+
+// otlpStatusError is the status code of a span in error
+const otlpStatusError = 2
+
+// isError returns true if the status is an error
+func (s *otlpStatus) isError() bool {
+
+	if s == nil {
+		return false
+	}
+
+	switch c := s.Code.(type) {
+	case float64:
+		return c == otlpStatusError
+	case string:
+		return c == "STATUS_CODE_ERROR"
+	}
+
+	return false
+}
+
+// value returns the Go value and the jaeger type of the attribute value
+func (v otlpAnyValue) value() (any, string) {
+
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue, "string"
+	case v.BoolValue != nil:
+		return *v.BoolValue, "bool"
+	case v.IntValue != nil:
+		return int64(*v.IntValue), "int64"
+	case v.DoubleValue != nil:
+		return *v.DoubleValue, "float64"
+	}
+
+	return "", "string"
+}
+
+// otlpID returns the hex representation of an OTLP ID that
+// can be encoded in hexadecimal or in base64 as in proto3
+func otlpID(id string) string {
+
+	if _, err := hex.DecodeString(id); err == nil && (len(id) == 16 || len(id) == 32) {
+		return strings.ToLower(id)
+	}
+
+	if data, err := base64.StdEncoding.DecodeString(id); err == nil {
+		return hex.EncodeToString(data)
+	}
+
+	return id
+}
+
+// toTrace converts the OTLP trace to the jaeger model
+func (o otlpTraces) toTrace() Trace {
+
+	t := Trace{Processes: map[string]Process{}}
+
+	for i, rs := range append(o.ResourceSpans, o.Batches...) {
+
+		processID := fmt.Sprintf("p%d", i+1)
+		process := Process{}
+
+		for _, a := range rs.Resource.Attributes {
+			v, typ := a.Value.value()
+			if a.Key == "service.name" {
+				process.ServiceName = fmt.Sprint(v)
+				continue
+			}
+			process.Tags = append(process.Tags, KeyValue{Key: a.Key, Type: typ, Value: v})
+		}
+
+		t.Processes[processID] = process
+
+		for _, ss := range append(rs.ScopeSpans, rs.InstrumentationLibrarySpans...) {
+			for _, s := range ss.Spans {
+
+				span := Span{
+					TraceID:       otlpID(s.TraceID),
+					SpanID:        otlpID(s.SpanID),
+					OperationName: s.Name,
+					StartTime:     int64(s.StartTimeUnixNano) / 1000,
+					Duration:      int64(s.EndTimeUnixNano-s.StartTimeUnixNano) / 1000,
+					ProcessID:     processID,
+				}
+
+				if s.ParentSpanID != "" {
+					span.References = []Reference{{RefType: "CHILD_OF", TraceID: span.TraceID, SpanID: otlpID(s.ParentSpanID)}}
+				}
+
+				for _, a := range s.Attributes {
+					v, typ := a.Value.value()
+					span.Tags = append(span.Tags, KeyValue{Key: a.Key, Type: typ, Value: v})
+				}
+
+				if s.Status.isError() && !func() bool {
+					for _, tag := range span.Tags {
+						if tag.Key == "error" {
+							return true
+						}
+					}
+					return false
+				}() {
+					span.Tags = append(span.Tags, KeyValue{Key: "error", Type: "bool", Value: true})
+				}
+
+				for _, e := range s.Events {
+					log := Log{
+						Timestamp: int64(e.TimeUnixNano) / 1000,
+						Fields:    []KeyValue{{Key: "event", Type: "string", Value: e.Name}},
+					}
+					for _, a := range e.Attributes {
+						v, typ := a.Value.value()
+						log.Fields = append(log.Fields, KeyValue{Key: a.Key, Type: typ, Value: v})
+					}
+					span.Logs = append(span.Logs, log)
+				}
+
+				if t.TraceID == "" {
+					t.TraceID = span.TraceID
+				}
+
+				t.Spans = append(t.Spans, span)
+			}
+		}
+	}
+
+	return t
+}
@@ -0,0 +1,113 @@
+package monitoring
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// patternMasks are the variable tokens masked to build the templates, in order
+var patternMasks = []struct {
+	re   *regexp.Regexp
+	mask string
+}{
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`), "<ts>"},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), "<uuid>"},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{16,}\b`), "<id>"},
+	{regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`), "<ip>"},
+	{regexp.MustCompile(`(?i)\b(namespace|ns)(["']?\s*[=:]?\s*["']?)(/[\w.-]+)+`), "$1$2<ns>"},
+	{regexp.MustCompile(`\b\d+(\.\d+)?(ns|us|µs|ms|s|m|h)?\b`), "<num>"},
+}
+
+// LogPattern is a group of log lines sharing the same template
+type LogPattern struct {
+	Template string
+	Count    int
+	First    time.Time
+	Last     time.Time
+	Example  string
+	Pods     []string
+}
+
+// LogPatterns groups log lines into patterns
+type LogPatterns struct {
+	patterns map[string]*LogPattern
+	pods     map[string]map[string]struct{}
+}
+
+// NewLogPatterns returns an empty LogPatterns
+func NewLogPatterns() *LogPatterns {
+	return &LogPatterns{
+		patterns: map[string]*LogPattern{},
+		pods:     map[string]map[string]struct{}{},
+	}
+}
+
+// Add adds an entry to its pattern
+func (l *LogPatterns) Add(e LogEntry) {
+
+	template := LogTemplate(e.Line)
+
+	p, ok := l.patterns[template]
+	if !ok {
+		p = &LogPattern{Template: template, First: e.Timestamp, Last: e.Timestamp, Example: strings.TrimSpace(e.Line)}
+		l.patterns[template] = p
+		l.pods[template] = map[string]struct{}{}
+	}
+
+	p.Count++
+	if e.Timestamp.Before(p.First) {
+		p.First = e.Timestamp
+	}
+	if e.Timestamp.After(p.Last) {
+		p.Last = e.Timestamp
+	}
+
+	if pod, ok := e.Labels["pod"]; ok {
+		l.pods[template][pod] = struct{}{}
+	}
+}
+
+// Patterns returns the patterns sorted by decreasing count
+func (l *LogPatterns) Patterns() []LogPattern {
+
+	patterns := make([]LogPattern, 0, len(l.patterns))
+
+	for template, p := range l.patterns {
+
+		pattern := *p
+		pattern.Pods = make([]string, 0, len(l.pods[template]))
+		for pod := range l.pods[template] {
+			pattern.Pods = append(pattern.Pods, pod)
+		}
+		sort.Strings(pattern.Pods)
+
+		patterns = append(patterns, pattern)
+	}
+
+	sort.Slice(patterns, func(i, j int) bool {
+		if patterns[i].Count != patterns[j].Count {
+			return patterns[i].Count > patterns[j].Count
+		}
+		return patterns[i].Template < patterns[j].Template
+	})
+
+	return patterns
+}
+
+// LogTemplate returns the template of a log line with the variable tokens masked.
+// For zap JSON lines, only the level and the message are kept.
+func LogTemplate(line string) string {
+
+	if z, ok := ParseZapLine(line); ok {
+		line = strings.ToUpper(z.Level) + " " + z.Message
+	}
+
+	line = strings.TrimSpace(line)
+	for _, m := range patternMasks {
+		line = m.re.ReplaceAllString(line, m.mask)
+	}
+
+	return line
+}
@@ -0,0 +1,75 @@
+package monitoring
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLogTemplate(t *testing.T) {
+
+	tests := []struct {
+		line string
+		want string
+	}{
+		{
+			"retrieved processingunit 5f3a2b1c0d9e8f7a6b5c4d3e in namespace /apomux/foo/bar",
+			"retrieved processingunit <id> in namespace <ns>",
+		},
+		{
+			`request failed ns=/apomux/foo namespace: "/apomux/bar"`,
+			`request failed ns=<ns> namespace: "<ns>"`,
+		},
+		{
+			"GET /policy/processingunits 403 in 12ns",
+			"GET /policy/processingunits <num> in <num>",
+		},
+		{
+			"2023-11-14T22:13:20.123Z request from 10.0.12.3:4433 took 12.5ms",
+			"<ts> request from <ip> took <num>",
+		},
+		{
+			"session 0b1e9c2a-7d44-4e8b-9a3c-2f1d0e9b8a7c expired after 3 retries",
+			"session <uuid> expired after <num> retries",
+		},
+		{
+			`{"level":"error","ts":1700000000,"msg":"unable to reach mongo","attempt":3}`,
+			"ERROR unable to reach mongo",
+		},
+		{
+			"GET /issue 500",
+			"GET /issue <num>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := LogTemplate(tt.line); got != tt.want {
+				t.Errorf("LogTemplate() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogPatterns(t *testing.T) {
+
+	base := time.Unix(1700000000, 0)
+	entry := func(offset int, pod string, line string) LogEntry {
+		return LogEntry{Timestamp: base.Add(time.Duration(offset) * time.Second), Labels: LogLabels{"pod": pod}, Line: line}
+	}
+
+	patterns := NewLogPatterns()
+	patterns.Add(entry(2, "squall-2", "took 12ms"))
+	patterns.Add(entry(0, "squall-1", "took 3ms"))
+	patterns.Add(entry(1, "cid-1", "boom"))
+	patterns.Add(entry(5, "squall-1", "took 40ms"))
+
+	want := []LogPattern{
+		{Template: "took <num>", Count: 3, First: base, Last: base.Add(5 * time.Second), Example: "took 12ms", Pods: []string{"squall-1", "squall-2"}},
+		{Template: "boom", Count: 1, First: base.Add(time.Second), Last: base.Add(time.Second), Example: "boom", Pods: []string{"cid-1"}},
+	}
+
+	if got := patterns.Patterns(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Patterns() = %+v, want %+v", got, want)
+	}
+}
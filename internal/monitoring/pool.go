@@ -0,0 +1,28 @@
+package monitoring
+
+import "sync"
+
+// ForEach calls fn for each index from 0 to count-1 on a bounded pool of workers
+// and returns once all the calls are done. fn must be safe for concurrent use.
+func ForEach(count int, workers int, fn func(index int)) {
+
+	var wg sync.WaitGroup
+
+	jobs := make(chan int)
+	for w := 0; w < min(max(workers, 1), count); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				fn(index)
+			}
+		}()
+	}
+
+	for i := 0; i < count; i++ {
+		jobs <- i
+	}
+	close(jobs)
+
+	wg.Wait()
+}
@@ -0,0 +1,43 @@
+package monitoring
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestForEach(t *testing.T) {
+
+	var (
+		lock    sync.Mutex
+		running int
+		peak    int
+	)
+
+	seen := make([]int, 20)
+	ForEach(len(seen), 3, func(index int) {
+
+		lock.Lock()
+		running++
+		peak = max(peak, running)
+		lock.Unlock()
+
+		seen[index]++
+
+		lock.Lock()
+		running--
+		lock.Unlock()
+	})
+
+	for i, n := range seen {
+		if n != 1 {
+			t.Errorf("ForEach() called index %d %d times, want 1", i, n)
+		}
+	}
+
+	if peak > 3 {
+		t.Errorf("ForEach() ran %d workers at once, want at most 3", peak)
+	}
+
+	// Nothing to do
+	ForEach(0, 3, func(int) { t.Errorf("ForEach() called with no items") })
+}
@@ -0,0 +1,140 @@
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxRetries is the number of retries on transient errors
+const maxRetries = 3
+
+// retryBackoff is the initial backoff between retries, doubled at each retry
+var retryBackoff = 500 * time.Millisecond
+
+// rateLimiter limits the number of requests per second to a backend
+type rateLimiter struct {
+	lock     sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newRateLimiter returns a rateLimiter allowing rps requests per second,
+// the rates above one request per nanosecond are not limited
+func newRateLimiter(rps int) *rateLimiter {
+	return &rateLimiter{interval: time.Second / time.Duration(rps)}
+}
+
+// wait blocks until the next request is allowed
+func (r *rateLimiter) wait() {
+
+	r.lock.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	delay := r.next.Sub(now)
+	r.next = r.next.Add(r.interval)
+	r.lock.Unlock()
+
+	time.Sleep(delay)
+}
+
+// SetRateLimit limits the number of requests per second sent to the datasource proxy.
+// It must be called before the client is used concurrently, 0 means unlimited.
+func (m *Client) SetRateLimit(proxy int, rps int) {
+
+	if rps <= 0 {
+		delete(m.limiters, proxy)
+		return
+	}
+
+	m.limiters[proxy] = newRateLimiter(rps)
+}
+
+// SetConcurrency sets the number of concurrent trace lookups of the backends
+// that fetch the traces one by one after a search. It must be called before
+// the tracing backend is created.
+func (m *Client) SetConcurrency(workers int) {
+	m.concurrency = workers
+}
+
+// do sends an idempotent request to the datasource proxy. It waits for the rate limit
+// of the proxy if any and retries with an exponential backoff on timeouts and 5xx errors.
+func (m Client) do(proxy int, request *http.Request) (*http.Response, error) {
+
+	backoff := retryBackoff
+
+	for attempt := 0; ; attempt++ {
+
+		if limiter, ok := m.limiters[proxy]; ok {
+			limiter.wait()
+		}
+
+		resp, err := m.client.Do(request)
+		if attempt == maxRetries || !isTransient(resp, err) {
+			return resp, err
+		}
+
+		if resp != nil {
+			resp.Body.Close() // nolint
+		}
+
+		zap.L().Debug("Retrying request",
+			zap.String("url", request.URL.String()),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+
+		time.Sleep(backoff + time.Duration(rand.Int63n(int64(backoff/2)+1)))
+		backoff *= 2
+	}
+}
+
+// isTransient returns true if the request can be retried
+func isTransient(resp *http.Response, err error) bool {
+
+	if err != nil {
+		var netErr net.Error
+		return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+	}
+
+	return resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// statusError is returned when the backend answers with an unexpected status code
+type statusError struct {
+	code   int
+	reason string
+}
+
+func (e statusError) Error() string {
+
+	if e.reason != "" {
+		return fmt.Sprintf("return code %d: %s", e.code, e.reason)
+	}
+
+	return fmt.Sprintf("return code %d", e.code)
+}
+
+// isDisconnection returns true if the error is a lost connection or a transient
+// error of the backend, false if the request was rejected
+func isDisconnection(err error) bool {
+
+	var status statusError
+	if errors.As(err, &status) {
+		return isTransient(&http.Response{StatusCode: status.code}, nil)
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) || isTransient(nil, err)
+}
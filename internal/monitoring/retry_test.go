@@ -0,0 +1,108 @@
+package monitoring
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestClient_do(t *testing.T) {
+
+	retryBackoff = time.Millisecond
+
+	tests := []struct {
+		name         string
+		failures     int
+		failureCode  int
+		wantCode     int
+		wantAttempts int
+	}{
+		{
+			"success",
+			0,
+			http.StatusBadGateway,
+			http.StatusOK,
+			1,
+		},
+		{
+			"retry transient errors",
+			2,
+			http.StatusBadGateway,
+			http.StatusOK,
+			3,
+		},
+		{
+			"give up after max retries",
+			10,
+			http.StatusServiceUnavailable,
+			http.StatusServiceUnavailable,
+			maxRetries + 1,
+		},
+		{
+			"do not retry client errors",
+			10,
+			http.StatusForbidden,
+			http.StatusForbidden,
+			1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				if attempts <= tt.failures {
+					w.WriteHeader(tt.failureCode)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			u, _ := url.Parse(server.URL)
+			m := &Client{url: u, limiters: map[int]*rateLimiter{}}
+			m.SetRateLimit(1, 1000)
+
+			request, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := m.do(1, request)
+			if err != nil {
+				t.Fatalf("do() error = %v", err)
+			}
+			defer resp.Body.Close() // nolint
+
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("do() code = %v, want %v", resp.StatusCode, tt.wantCode)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("do() attempts = %v, want %v", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+
+	r := newRateLimiter(100)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		r.wait()
+	}
+
+	// The first request is immediate, the next ones are spaced by 10ms
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("wait() allowed 5 requests in %v, want at least 40ms", elapsed)
+	}
+
+	// A rate above one request per nanosecond is not limited
+	r = newRateLimiter(2e9)
+	r.wait()
+	r.wait()
+}
@@ -0,0 +1,77 @@
+package monitoring
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aporeto-inc/tracer/internal/profiles"
+)
+
+// serviceRule is a compiled profiles.ServiceRule
+type serviceRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// ServiceMapper resolves the tracing service name of a metrics service
+type ServiceMapper struct {
+	names map[string]string
+	rules []serviceRule
+	known map[string]struct{}
+}
+
+// NewServiceMapper returns a new ServiceMapper from the mapping of a datasource
+// and the services known by the tracing backend, if any.
+func NewServiceMapper(mapping profiles.ServiceMapping, known []string) (*ServiceMapper, error) {
+
+	s := &ServiceMapper{
+		names: mapping.Names,
+		known: make(map[string]struct{}, len(known)),
+	}
+
+	for _, r := range mapping.Rules {
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid service mapping rule %s: %w", r.Pattern, err)
+		}
+		s.rules = append(s.rules, serviceRule{pattern: pattern, replacement: r.Replacement})
+	}
+
+	for _, k := range known {
+		s.known[k] = struct{}{}
+	}
+
+	return s, nil
+}
+
+// Resolve returns the tracing service name of a metrics service.
+// The explicit names are used first, then the first matching rule and
+// finally the longest dash separated prefix known by the tracing backend.
+// If the known services are not available the first dash separated part is used.
+func (s *ServiceMapper) Resolve(service string) (string, bool) {
+
+	if name, ok := s.names[service]; ok {
+		return name, true
+	}
+
+	for _, r := range s.rules {
+		if r.pattern.MatchString(service) {
+			return r.pattern.ReplaceAllString(service, r.replacement), true
+		}
+	}
+
+	if len(s.known) == 0 {
+		return strings.Split(service, "-")[0], true
+	}
+
+	parts := strings.Split(service, "-")
+	for i := len(parts); i > 0; i-- {
+		candidate := strings.Join(parts[:i], "-")
+		if _, ok := s.known[candidate]; ok {
+			return candidate, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,98 @@
+package monitoring
+
+import (
+	"testing"
+
+	"github.com/aporeto-inc/tracer/internal/profiles"
+)
+
+func TestServiceMapper_Resolve(t *testing.T) {
+	type args struct {
+		mapping profiles.ServiceMapping
+		known   []string
+		service string
+	}
+	tests := []struct {
+		name   string
+		args   args
+		want   string
+		wantOK bool
+	}{
+		{
+			"legacy prefix without known services",
+			args{
+				service: "sephiroth-api",
+			},
+			"sephiroth",
+			true,
+		},
+		{
+			"explicit name",
+			args{
+				mapping: profiles.ServiceMapping{
+					Names: map[string]string{"zack": "zack-reports"},
+				},
+				known:   []string{"zack"},
+				service: "zack",
+			},
+			"zack-reports",
+			true,
+		},
+		{
+			"rule",
+			args{
+				mapping: profiles.ServiceMapping{
+					Rules: []profiles.ServiceRule{
+						{Pattern: "^(.*)-worker$", Replacement: "${1}-jobs"},
+					},
+				},
+				known:   []string{"squall"},
+				service: "squall-worker",
+			},
+			"squall-jobs",
+			true,
+		},
+		{
+			"known service with dashes",
+			args{
+				known:   []string{"sephiroth", "sephiroth-api"},
+				service: "sephiroth-api",
+			},
+			"sephiroth-api",
+			true,
+		},
+		{
+			"known prefix",
+			args{
+				known:   []string{"squall", "cid"},
+				service: "squall-canary-1",
+			},
+			"squall",
+			true,
+		},
+		{
+			"unknown service",
+			args{
+				known:   []string{"squall", "cid"},
+				service: "midgard",
+			},
+			"",
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewServiceMapper(tt.args.mapping, tt.args.known)
+			if err != nil {
+				t.Fatalf("NewServiceMapper() error = %v", err)
+			}
+			got, gotOK := s.Resolve(tt.args.service)
+			if got != tt.want {
+				t.Errorf("Resolve() got = %v, want %v", got, tt.want)
+			}
+			if gotOK != tt.wantOK {
+				t.Errorf("Resolve() gotOK = %v, want %v", gotOK, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.aporeto.io/elemental"
+)
+
+// errorKeys are the span tags and log fields that may hold an error message
+var errorKeys = []string{"error.object", "error.message", "error", "message"}
+
+// elementalErrorString matches the string representation of an elemental.Error
+var elementalErrorString = regexp.MustCompile(`^error (\d+) \(([^)]*)\): ([^:]+): (.*)$`)
+
+// ErrorMessage is an error message recorded by a span
+type ErrorMessage struct {
+	Title       string
+	Description string
+	Subject     string
+}
+
+// String returns a short representation of the message
+func (e ErrorMessage) String() string {
+
+	msg := e.Description
+	if e.Title != "" && e.Description != "" {
+		msg = fmt.Sprintf("%s: %s", e.Title, e.Description)
+	} else if e.Title != "" {
+		msg = e.Title
+	}
+
+	if e.Subject != "" {
+		msg = fmt.Sprintf("%s (%s)", msg, e.Subject)
+	}
+
+	return msg
+}
+
+// ErrorSummary is the most common error message over a set of traces
+type ErrorSummary struct {
+	Message ErrorMessage
+	Count   int
+	Total   int
+}
+
+// String returns a short representation of the summary
+func (s *ErrorSummary) String() string {
+
+	if s == nil {
+		return ""
+	}
+
+	if s.Total == 1 {
+		return s.Message.String()
+	}
+
+	return fmt.Sprintf("%s [%d/%d]", s.Message, s.Count, s.Total)
+}
+
+// ExtractErrors returns the error messages of the spans in error.
+// At most one message is returned per span, and the spans in error
+// without any message are ignored.
+func ExtractErrors(traces []Trace) []ErrorMessage {
+
+	messages := []ErrorMessage{}
+
+	for _, t := range traces {
+		for _, s := range t.Spans {
+
+			if !s.IsError() {
+				continue
+			}
+
+			if msg, ok := spanError(s); ok {
+				messages = append(messages, msg)
+			}
+		}
+	}
+
+	return messages
+}
+
+// SummarizeErrors returns the most common error message of the spans in error.
+// It returns nil if no message has been found.
+func SummarizeErrors(traces []Trace) *ErrorSummary {
+
+	messages := ExtractErrors(traces)
+	if len(messages) == 0 {
+		return nil
+	}
+
+	counts := map[ErrorMessage]int{}
+	for _, m := range messages {
+		counts[m]++
+	}
+
+	summaries := make([]ErrorSummary, 0, len(counts))
+	for m, c := range counts {
+		summaries = append(summaries, ErrorSummary{Message: m, Count: c, Total: len(messages)})
+	}
+
+	// Most common first, then alphabetically to be stable
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Count != summaries[j].Count {
+			return summaries[i].Count > summaries[j].Count
+		}
+		return summaries[i].Message.String() < summaries[j].Message.String()
+	})
+
+	return &summaries[0]
+}
+
+// spanError returns the error message of a span. The logs are looked up
+// first as they usually hold the full error, then the tags.
+// Elemental errors are preferred over plain messages.
+func spanError(s Span) (ErrorMessage, bool) {
+
+	values := []string{}
+	for _, l := range s.Logs {
+		values = append(values, errorValues(l.Fields)...)
+	}
+	values = append(values, errorValues(s.Tags)...)
+
+	var plain *ErrorMessage
+	for _, v := range values {
+
+		if msg, ok := parseElementalError(v); ok {
+			return msg, true
+		}
+
+		if plain == nil {
+			plain = &ErrorMessage{Description: v}
+		}
+	}
+
+	if plain == nil {
+		return ErrorMessage{}, false
+	}
+
+	return *plain, true
+}
+
+// errorValues returns the values of the key values that may hold an error message
+func errorValues(kvs []KeyValue) []string {
+
+	values := []string{}
+
+	for _, key := range errorKeys {
+		for _, kv := range kvs {
+
+			if kv.Key != key {
+				continue
+			}
+
+			// The error tag is usually a boolean flag
+			if _, ok := kv.Value.(bool); ok {
+				continue
+			}
+
+			v := strings.TrimSpace(tagString(kv))
+			if v == "" || v == "true" || v == "false" {
+				continue
+			}
+
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
+
+// parseElementalError decodes an elemental error from its JSON
+// or string representation. Only the first error of a list is kept.
+func parseElementalError(v string) (ErrorMessage, bool) {
+
+	var e elemental.Error
+
+	switch {
+
+	case strings.HasPrefix(v, "["):
+		errs := elemental.Errors{}
+		if err := json.Unmarshal([]byte(v), &errs); err != nil || len(errs) == 0 {
+			return ErrorMessage{}, false
+		}
+		e = errs[0]
+
+	case strings.HasPrefix(v, "{"):
+		if err := json.Unmarshal([]byte(v), &e); err != nil {
+			return ErrorMessage{}, false
+		}
+
+	default:
+		m := elementalErrorString.FindStringSubmatch(v)
+		if m == nil {
+			return ErrorMessage{}, false
+		}
+		// Only the first error of a list is kept
+		description, _, _ := strings.Cut(m[4], ", error ")
+		return ErrorMessage{Title: m[3], Description: description, Subject: m[2]}, true
+	}
+
+	if e.Title == "" && e.Description == "" {
+		return ErrorMessage{}, false
+	}
+
+	return ErrorMessage{Title: e.Title, Description: e.Description, Subject: e.Subject}, true
+}
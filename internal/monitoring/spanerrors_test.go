@@ -0,0 +1,105 @@
+package monitoring
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseElementalError(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		value string
+		want  ErrorMessage
+		ok    bool
+	}{
+		{
+			name:  "json",
+			value: `{"code":422,"title":"Validation Error","description":"Attribute 'name' is required","subject":"squall"}`,
+			want:  ErrorMessage{Title: "Validation Error", Description: "Attribute 'name' is required", Subject: "squall"},
+			ok:    true,
+		},
+		{
+			name:  "json list",
+			value: `[{"code":500,"title":"Internal Server Error","description":"boom","subject":"cid"},{"code":500,"title":"Other","description":"bam"}]`,
+			want:  ErrorMessage{Title: "Internal Server Error", Description: "boom", Subject: "cid"},
+			ok:    true,
+		},
+		{
+			name:  "string",
+			value: "error 404 (gaga): Not Found: Unable to find the object, error 500 (gaga): Internal Server Error: boom",
+			want:  ErrorMessage{Title: "Not Found", Description: "Unable to find the object", Subject: "gaga"},
+			ok:    true,
+		},
+		{
+			name:  "empty json",
+			value: `{"code":500}`,
+		},
+		{
+			name:  "invalid json",
+			value: `{"code":`,
+		},
+		{
+			name:  "plain",
+			value: "context deadline exceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseElementalError(tt.value)
+			if ok != tt.ok {
+				t.Fatalf("parseElementalError() ok = %v, want %v", ok, tt.ok)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseElementalError() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeErrors(t *testing.T) {
+
+	elementalError := `{"code":500,"title":"Internal Server Error","description":"unable to reach mongo","subject":"squall"}`
+
+	traces := []Trace{
+		{
+			TraceID: "a",
+			Spans: []Span{
+				// Not in error
+				{SpanID: "1", Tags: []KeyValue{{Key: "error", Value: false}, {Key: "message", Value: "ignored"}}},
+				// Elemental error in the logs
+				{SpanID: "2", Tags: []KeyValue{{Key: "error", Value: true}, {Key: "message", Value: "plain"}}, Logs: []Log{{Fields: []KeyValue{{Key: "event", Value: "error"}, {Key: "error.object", Value: elementalError}}}}},
+				// Plain error only
+				{SpanID: "3", Tags: []KeyValue{{Key: "error", Value: true}, {Key: "error.message", Value: "context deadline exceeded"}}},
+				// In error without message
+				{SpanID: "4", Tags: []KeyValue{{Key: "error", Value: "true"}}},
+			},
+		},
+		{
+			TraceID: "b",
+			Spans: []Span{
+				{SpanID: "1", Tags: []KeyValue{{Key: "error", Value: true}, {Key: "error", Value: elementalError}}},
+			},
+		},
+	}
+
+	want := []ErrorMessage{
+		{Title: "Internal Server Error", Description: "unable to reach mongo", Subject: "squall"},
+		{Description: "context deadline exceeded"},
+		{Title: "Internal Server Error", Description: "unable to reach mongo", Subject: "squall"},
+	}
+
+	if got := ExtractErrors(traces); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ExtractErrors() = %+v, want %+v", got, want)
+	}
+
+	summary := SummarizeErrors(traces)
+	if s := summary.String(); s != "Internal Server Error: unable to reach mongo (squall) [2/3]" {
+		t.Errorf("SummarizeErrors() = %s", s)
+	}
+
+	if summary := SummarizeErrors(nil); summary != nil || summary.String() != "" {
+		t.Errorf("SummarizeErrors(nil) = %+v", summary)
+	}
+}
@@ -0,0 +1,158 @@
+package monitoring
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// tempoBackend is the grafana tempo implementation of the TraceBackend
+type tempoBackend struct {
+	client Client
+	proxy  int
+}
+
+// tempoSearchResult is the result of a tempo search
+type tempoSearchResult struct {
+	Traces []struct {
+		TraceID string `json:"traceID"`
+	} `json:"traces"`
+}
+
+// tempoTagValuesResult is the result of a tempo tag values listing
+type tempoTagValuesResult struct {
+	TagValues []string `json:"tagValues"`
+}
+
+// SearchTraces implements the TraceBackend interface. As the search
+// API only returns a summary the matching traces are then fetched
+// concurrently, within the rate limit of the proxy.
+func (t tempoBackend) SearchTraces(params TracingQueryParameters) ([]Trace, error) {
+
+	q := url.Values{}
+	q.Set("q", traceQL(params))
+	q.Set("start", strconv.FormatInt(params.Start/1000000, 10))
+	q.Set("end", strconv.FormatInt(params.End/1000000, 10))
+	if params.Limit > 0 {
+		q.Set("limit", strconv.Itoa(params.Limit))
+	}
+
+	p := &tempoSearchResult{}
+	if err := t.client.getJSON(t.proxy, "api/search", q, p); err != nil {
+		return nil, fmt.Errorf("unable to search traces: %w", err)
+	}
+
+	zap.L().Debug("Query tempo", zap.String("query", q.Get("q")), zap.Int("results", len(p.Traces)))
+
+	traces := make([]Trace, len(p.Traces))
+	errs := make([]error, len(p.Traces))
+	ForEach(len(p.Traces), t.client.concurrency, func(i int) {
+		traces[i], errs[i] = t.GetTrace(p.Traces[i].TraceID)
+	})
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return traces, nil
+}
+
+// GetTrace implements the TraceBackend interface
+func (t tempoBackend) GetTrace(id string) (Trace, error) {
+
+	p := &otlpTraces{}
+	if err := t.client.getJSON(t.proxy, "api/traces/"+url.PathEscape(id), nil, p); err != nil {
+		return Trace{}, fmt.Errorf("unable to get trace %s: %w", id, err)
+	}
+
+	trace := p.toTrace()
+	if len(trace.Spans) == 0 {
+		return Trace{}, fmt.Errorf("unable to get trace %s: not found", id)
+	}
+
+	return trace, nil
+}
+
+// ListServices implements the TraceBackend interface
+func (t tempoBackend) ListServices() ([]string, error) {
+
+	p := &tempoTagValuesResult{}
+	if err := t.client.getJSON(t.proxy, "api/search/tag/service.name/values", nil, p); err != nil {
+		return nil, fmt.Errorf("unable to get services: %w", err)
+	}
+
+	zap.L().Debug("Query tempo services", zap.Strings("services", p.TagValues))
+
+	return p.TagValues, nil
+}
+
+// traceQL builds the TraceQL query matching the same spans as the jaeger search
+func traceQL(params TracingQueryParameters) string {
+
+	conditions := []string{}
+
+	if params.Service != "" {
+		conditions = append(conditions, fmt.Sprintf("resource.service.name = %s", strconv.Quote(params.Service)))
+	}
+
+	if params.Operation != "" {
+		conditions = append(conditions, fmt.Sprintf("name = %s", strconv.Quote(params.Operation)))
+	}
+
+	if params.MinDuration > 0 {
+		conditions = append(conditions, fmt.Sprintf("duration >= %s", params.MinDuration))
+	}
+
+	if params.MaxDuration > 0 {
+		conditions = append(conditions, fmt.Sprintf("duration <= %s", params.MaxDuration))
+	}
+
+	keys := make([]string, 0, len(params.Tags))
+	for k := range params.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+
+		v := params.Tags[k]
+
+		// The jaeger error tag is the span status in OpenTelemetry
+		if k == "error" && v == "true" {
+			conditions = append(conditions, "status = error")
+			continue
+		}
+
+		conditions = append(conditions, fmt.Sprintf("span.%s = %s", k, traceQLValue(k, v)))
+	}
+
+	if len(conditions) == 0 {
+		return "{}"
+	}
+
+	return "{ " + strings.Join(conditions, " && ") + " }"
+}
+
+// traceQLNumbers are the span attributes compared as numbers,
+// the values of the other attributes are compared as strings
+var traceQLNumbers = map[string]bool{
+	"status.code":               true,
+	"http.status_code":          true,
+	"http.response.status_code": true,
+}
+
+// traceQLValue returns the TraceQL literal of a tag value
+func traceQLValue(key string, v string) string {
+
+	if _, err := strconv.ParseInt(v, 10, 64); err == nil && traceQLNumbers[key] {
+		return v
+	}
+
+	return strconv.Quote(v)
+}
@@ -0,0 +1,188 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTraceQL(t *testing.T) {
+	tests := []struct {
+		name   string
+		params TracingQueryParameters
+		want   string
+	}{
+		{
+			"empty",
+			TracingQueryParameters{},
+			"{}",
+		},
+		{
+			"full",
+			TracingQueryParameters{
+				Service:     "squall",
+				Operation:   "retrieve-many processingunits",
+				MinDuration: 2 * time.Second,
+				MaxDuration: 3 * time.Second,
+				Tags: Tags{
+					"error":         "true",
+					"status.code":   "403",
+					"req.identity":  "processingunit",
+					"req.namespace": "/foo/bar",
+				},
+			},
+			`{ resource.service.name = "squall" && name = "retrieve-many processingunits" && duration >= 2s && duration <= 3s && status = error && span.req.identity = "processingunit" && span.req.namespace = "/foo/bar" && span.status.code = 403 }`,
+		},
+		{
+			"numbers are only typed for known attributes",
+			TracingQueryParameters{
+				Tags: Tags{
+					"http.status_code": "403",
+					"req.namespace":    "123",
+					"cache.hit":        "true",
+				},
+			},
+			`{ span.cache.hit = "true" && span.http.status_code = 403 && span.req.namespace = "123" }`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := traceQL(tt.params); got != tt.want {
+				t.Errorf("traceQL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOTLPToTrace(t *testing.T) {
+
+	data := `{
+		"batches": [{
+			"resource": {"attributes": [
+				{"key": "service.name", "value": {"stringValue": "squall"}},
+				{"key": "hostname", "value": {"stringValue": "squall-1"}}
+			]},
+			"scopeSpans": [{"spans": [
+				{
+					"traceId": "AAAAAAAAAABqET0O+pslmw==",
+					"spanId": "ahE9DvqbJZs=",
+					"name": "retrieve-many processingunits",
+					"startTimeUnixNano": "1600000000000000000",
+					"endTimeUnixNano": "1600000000200000000",
+					"attributes": [{"key": "status.code", "value": {"intValue": "403"}}],
+					"status": {"code": 2}
+				},
+				{
+					"traceId": "AAAAAAAAAABqET0O+pslmw==",
+					"spanId": "SRzPyvWo40M=",
+					"parentSpanId": "ahE9DvqbJZs=",
+					"name": "mongo",
+					"startTimeUnixNano": 1600000000100000000,
+					"endTimeUnixNano": 1600000000150000000,
+					"events": [{"timeUnixNano": "1600000000120000000", "name": "query", "attributes": [{"key": "slow", "value": {"boolValue": true}}]}]
+				}
+			]}]
+		}]
+	}`
+
+	o := otlpTraces{}
+	if err := json.Unmarshal([]byte(data), &o); err != nil {
+		t.Fatalf("unable to decode otlp trace: %s", err)
+	}
+
+	want := Trace{
+		TraceID: "00000000000000006a113d0efa9b259b",
+		Processes: map[string]Process{
+			"p1": {ServiceName: "squall", Tags: []KeyValue{{Key: "hostname", Type: "string", Value: "squall-1"}}},
+		},
+		Spans: []Span{
+			{
+				TraceID:       "00000000000000006a113d0efa9b259b",
+				SpanID:        "6a113d0efa9b259b",
+				OperationName: "retrieve-many processingunits",
+				StartTime:     1600000000000000,
+				Duration:      200000,
+				ProcessID:     "p1",
+				Tags: []KeyValue{
+					{Key: "status.code", Type: "int64", Value: int64(403)},
+					{Key: "error", Type: "bool", Value: true},
+				},
+			},
+			{
+				TraceID:       "00000000000000006a113d0efa9b259b",
+				SpanID:        "491ccfcaf5a8e343",
+				OperationName: "mongo",
+				StartTime:     1600000000100000,
+				Duration:      50000,
+				ProcessID:     "p1",
+				References:    []Reference{{RefType: "CHILD_OF", TraceID: "00000000000000006a113d0efa9b259b", SpanID: "6a113d0efa9b259b"}},
+				Logs: []Log{
+					{
+						Timestamp: 1600000000120000,
+						Fields: []KeyValue{
+							{Key: "event", Type: "string", Value: "query"},
+							{Key: "slow", Type: "bool", Value: true},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if got := o.toTrace(); !reflect.DeepEqual(got, want) {
+		t.Errorf("toTrace() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTempoBackend_SearchTraces(t *testing.T) {
+
+	var (
+		lock    sync.Mutex
+		fetched []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.URL.Path == "/api/datasources/proxy/3/api/search" {
+			fmt.Fprint(w, `{"traces": [{"traceID": "6a113d0efa9b259b"}, {"traceID": "491cfcaef5a8e343"}, {"traceID": "211c4e34e7b643ff"}]}`)
+			return
+		}
+
+		id := strings.TrimPrefix(r.URL.Path, "/api/datasources/proxy/3/api/traces/")
+		lock.Lock()
+		fetched = append(fetched, id)
+		lock.Unlock()
+
+		fmt.Fprintf(w, `{"batches": [{"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "squall"}}]}, "scopeSpans": [{"spans": [{"traceId": "%[1]s", "spanId": "%[1]s", "name": "%[1]s"}]}]}]}`, id)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	m := Client{url: u, client: http.Client{Transport: http.DefaultTransport}, limiters: map[int]*rateLimiter{}}
+	m.SetConcurrency(2)
+
+	traces, err := tempoBackend{client: m, proxy: 3}.SearchTraces(TracingQueryParameters{Service: "squall"})
+	if err != nil {
+		t.Fatalf("SearchTraces() error = %v", err)
+	}
+
+	if len(fetched) != 3 {
+		t.Errorf("SearchTraces() fetched %v, want 3 traces", fetched)
+	}
+
+	// The traces keep the order of the search
+	got := []string{}
+	for _, trace := range traces {
+		got = append(got, trace.Spans[0].OperationName)
+	}
+	if want := []string{"6a113d0efa9b259b", "491cfcaef5a8e343", "211c4e34e7b643ff"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SearchTraces() = %v, want %v", got, want)
+	}
+}
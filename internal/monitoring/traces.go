@@ -1,18 +1,39 @@
 package monitoring
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"time"
 
-	"github.com/google/go-querystring/query"
 	"github.com/skratchdot/open-golang/open"
-	"go.uber.org/zap"
 )
 
+const (
+	// TraceBackendJaeger is the jaeger tracing backend
+	TraceBackendJaeger = "jaeger"
+
+	// TraceBackendTempo is the grafana tempo tracing backend
+	TraceBackendTempo = "tempo"
+)
+
+// TraceBackend is a tracing backend queried through the monitoring proxy
+type TraceBackend interface {
+
+	// SearchTraces returns the traces matching the parameters
+	SearchTraces(params TracingQueryParameters) ([]Trace, error)
+
+	// GetTrace returns the trace with the given ID
+	GetTrace(id string) (Trace, error)
+
+	// ListServices returns the services known by the backend
+	ListServices() ([]string, error)
+}
+
 // TracingQueryParameters represent the tracing query parameters
 type TracingQueryParameters struct {
 	End         int64         `url:"end"`
@@ -20,6 +41,7 @@ type TracingQueryParameters struct {
 	Loopback    time.Duration `url:"loopback"`
 	MaxDuration time.Duration `url:"maxDuration,omitempty"`
 	MinDuration time.Duration `url:"minDuration,omitempty"`
+	Operation   string        `url:"operation,omitempty"`
 	Service     string        `url:"service"`
 	Start       int64         `url:"start"`
 	Tags        `url:"tags,omitempty"`
@@ -39,57 +61,58 @@ func (t Tags) EncodeValues(key string, v *url.Values) error {
 	return nil
 }
 
-// traceResult is a result of a trace (sparse)
-type traceResult struct {
-	Data []struct {
-		TraceID string `json:"traceID"`
+// TraceBackend returns the tracing backend of the datasource behind the proxy
+func (m Client) TraceBackend(proxy int) (TraceBackend, error) {
+
+	switch m.cfg.TracesBackend {
+	case TraceBackendJaeger, "":
+		return jaegerBackend{client: m, proxy: proxy}, nil
+	case TraceBackendTempo:
+		return tempoBackend{client: m, proxy: proxy}, nil
+	default:
+		return nil, fmt.Errorf("unknown traces backend: %s", m.cfg.TracesBackend)
 	}
 }
 
-// GetTraceIDs try to find traces id related to errors seen in metrics
-func (m Client) GetTraceIDs(proxy int, params TracingQueryParameters) ([]string, error) {
+// getJSON queries a path of the datasource proxy and decodes the JSON response into out
+func (m Client) getJSON(proxy int, path string, values url.Values, out any) error {
 
-	jaegerProxy, err := url.Parse(fmt.Sprintf("api/datasources/proxy/%d/api/traces", proxy))
+	u, err := url.Parse(fmt.Sprintf("api/datasources/proxy/%d/%s", proxy, path))
 	if err != nil {
 		panic(err)
 	}
 
-	q, err := query.Values(params)
+	request, err := http.NewRequest(http.MethodGet, m.url.ResolveReference(u).String(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse query parameters: %w", err)
+		return fmt.Errorf("unable to create new request: %w", err)
 	}
 
-	request, err := http.NewRequest(http.MethodGet, m.url.ResolveReference(jaegerProxy).String(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("unable to create new request: %w", err)
-	}
-
-	request.URL.RawQuery = q.Encode()
+	request.URL.RawQuery = values.Encode()
+	request.Header.Set("Accept", "application/json")
 
-	resp, err := m.client.Do(request)
+	resp, err := m.do(proxy, request)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get traces: %w", err)
+		return fmt.Errorf("unable to send request: %w", err)
 	}
 	defer resp.Body.Close() // nolint
 
-	p := &traceResult{}
-	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
-		return nil, fmt.Errorf("unable to decode traces: %w", err)
+	if resp.StatusCode != http.StatusOK {
+		// Surface the reason given by the backend, such as a query parse error
+		if body, _ := io.ReadAll(io.LimitReader(resp.Body, 512)); len(bytes.TrimSpace(body)) > 0 {
+			return statusError{code: resp.StatusCode, reason: string(bytes.TrimSpace(body))}
+		}
+		return statusError{code: resp.StatusCode}
 	}
 
-	zap.L().Debug("Query jaeger", zap.Reflect("params", params), zap.Int("results", len(p.Data)))
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("unable to decode response: %w", err)
+	}
 
-	return func() []string {
-		res := []string{}
-		for _, i := range p.Data {
-			res = append(res, i.TraceID)
-		}
-		return res
-	}(), nil
+	return nil
 }
 
 // OpenTrace will open a trace in the browser
-func OpenTrace(u, datasource, trace string) {
+func OpenTrace(u, datasource, backend, trace string) {
 
 	toOpen, err := url.Parse(u)
 	if err != nil {
@@ -97,10 +120,15 @@ func OpenTrace(u, datasource, trace string) {
 		return
 	}
 
+	query := fmt.Sprintf(`{"query":"%s"}`, trace)
+	if backend == TraceBackendTempo {
+		query = fmt.Sprintf(`{"query":"%s","queryType":"traceql"}`, trace)
+	}
+
 	toOpen.Path = "explore"
 	q, _ := url.ParseQuery(toOpen.RawQuery)
 	q.Add("orgId", "1")
-	q.Add("left", fmt.Sprintf(`["now-24h","now","%s",{"query":"%s"}]`, datasource, trace))
+	q.Add("left", fmt.Sprintf(`["now-24h","now","%s",%s]`, datasource, query))
 
 	toOpen.RawQuery = q.Encode()
 
@@ -0,0 +1,182 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// zapLevels are the zap levels by increasing severity
+var zapLevels = []string{"debug", "info", "warn", "error", "dpanic", "panic", "fatal"}
+
+// zapLevelColors are the ANSI colors of the zap levels
+var zapLevelColors = map[string]string{
+	"debug":  "90",
+	"info":   "34",
+	"warn":   "33",
+	"error":  "31",
+	"dpanic": "91",
+	"panic":  "91",
+	"fatal":  "91",
+}
+
+// zapKeys are the keys of the zap JSON encoder that are not fields
+var zapKeys = map[string]struct{}{"level": {}, "ts": {}, "caller": {}, "msg": {}, "logger": {}}
+
+// ZapLine is a log line produced by the zap JSON encoder
+type ZapLine struct {
+	Level   string
+	Time    time.Time
+	Caller  string
+	Logger  string
+	Message string
+	Fields  map[string]any
+}
+
+// ParseZapLine parses a zap JSON log line. It returns false
+// if the line is not a JSON object with a level and a message.
+func ParseZapLine(line string) (ZapLine, bool) {
+
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return ZapLine{}, false
+	}
+
+	raw := map[string]any{}
+	if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		return ZapLine{}, false
+	}
+
+	level, _ := raw["level"].(string)
+	msg, ok := raw["msg"].(string)
+	if level == "" || !ok {
+		return ZapLine{}, false
+	}
+
+	z := ZapLine{
+		Level:   strings.ToLower(level),
+		Message: msg,
+		Fields:  map[string]any{},
+	}
+	z.Caller, _ = raw["caller"].(string)
+	z.Logger, _ = raw["logger"].(string)
+
+	// The time is either an epoch in seconds or an ISO8601 string
+	switch ts := raw["ts"].(type) {
+	case float64:
+		sec, frac := math.Modf(ts)
+		z.Time = time.Unix(int64(sec), int64(frac*1e9))
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.000Z0700"} {
+			if t, err := time.Parse(layout, ts); err == nil {
+				z.Time = t
+				break
+			}
+		}
+	}
+
+	for k, v := range raw {
+		if _, ok := zapKeys[k]; !ok {
+			z.Fields[k] = v
+		}
+	}
+
+	return z, true
+}
+
+// field returns the string representation of a field value
+func (z ZapLine) field(key string) (string, bool) {
+
+	v, ok := z.Fields[key]
+	if !ok {
+		return "", false
+	}
+
+	switch v.(type) {
+	case map[string]any, []any:
+		data, _ := json.Marshal(v) // nolint
+		return string(data), true
+	}
+
+	return tagString(KeyValue{Value: v}), true
+}
+
+// ZapFilter filters the log entries on their zap level and fields
+type ZapFilter struct {
+	MinLevel string
+	Fields   map[string]string
+}
+
+// IsEmpty returns true if the filter matches every entry
+func (f ZapFilter) IsEmpty() bool {
+	return zapSeverity(f.MinLevel) <= 0 && len(f.Fields) == 0
+}
+
+// Match returns true if the entry matches the filter. When the filter is
+// not empty, the lines that are not zap JSON never match.
+func (f ZapFilter) Match(e LogEntry) bool {
+
+	if f.IsEmpty() {
+		return true
+	}
+
+	z, ok := ParseZapLine(e.Line)
+	if !ok {
+		return false
+	}
+
+	if zapSeverity(z.Level) < zapSeverity(f.MinLevel) {
+		return false
+	}
+
+	for k, v := range f.Fields {
+		if value, ok := z.field(k); !ok || value != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// zapSeverity returns the severity of a level, -1 if unknown
+func zapSeverity(level string) int {
+
+	for i, l := range zapLevels {
+		if l == level {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// pretty returns a human readable representation of the zap line
+func (z ZapLine) pretty(colored bool) string {
+
+	level := fmt.Sprintf("%-5s", strings.ToUpper(z.Level))
+	if c, ok := zapLevelColors[z.Level]; ok && colored {
+		level = fmt.Sprintf("\x1b[%sm%s\x1b[0m", c, level)
+	}
+
+	parts := []string{level}
+	if z.Caller != "" {
+		parts = append(parts, z.Caller)
+	}
+	parts = append(parts, z.Message)
+
+	keys := make([]string, 0, len(z.Fields))
+	for k := range z.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v, _ := z.field(k)
+		parts = append(parts, k+"="+logfmtValue(v))
+	}
+
+	return strings.Join(parts, " ")
+}
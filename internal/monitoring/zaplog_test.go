@@ -0,0 +1,104 @@
+package monitoring
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseZapLine(t *testing.T) {
+
+	tests := []struct {
+		name string
+		line string
+		want ZapLine
+		ok   bool
+	}{
+		{
+			"epoch",
+			`{"level":"info","ts":1700000000.5,"caller":"squall/main.go:42","msg":"started","port":443}`,
+			ZapLine{Level: "info", Time: time.Unix(1700000000, 500000000), Caller: "squall/main.go:42", Message: "started", Fields: map[string]any{"port": float64(443)}},
+			true,
+		},
+		{
+			"iso8601",
+			`{"level":"ERROR","ts":"2023-11-14T22:13:20.000Z","logger":"cid","msg":"boom","error":"unable to reach mongo"}`,
+			ZapLine{Level: "error", Time: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC), Logger: "cid", Message: "boom", Fields: map[string]any{"error": "unable to reach mongo"}},
+			true,
+		},
+		{
+			"no level",
+			`{"msg":"hello"}`,
+			ZapLine{},
+			false,
+		},
+		{
+			"plain text",
+			`2023-11-14 ERROR boom`,
+			ZapLine{},
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseZapLine(tt.line)
+			if ok != tt.ok {
+				t.Fatalf("ParseZapLine() ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && !got.Time.Equal(tt.want.Time) {
+				t.Errorf("ParseZapLine() time = %v, want %v", got.Time, tt.want.Time)
+			}
+			got.Time, tt.want.Time = time.Time{}, time.Time{}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseZapLine() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestZapFilter_Match(t *testing.T) {
+
+	info := LogEntry{Line: `{"level":"info","msg":"hello","ns":"/foo","code":404,"obj":{"a":1}}`}
+	errorLine := LogEntry{Line: `{"level":"error","msg":"boom","ns":"/foo/bar"}`}
+	plain := LogEntry{Line: "ERROR boom"}
+
+	tests := []struct {
+		name   string
+		filter ZapFilter
+		entry  LogEntry
+		want   bool
+	}{
+		{"empty filter", ZapFilter{MinLevel: "debug"}, plain, true},
+		{"level below", ZapFilter{MinLevel: "warn"}, info, false},
+		{"level above", ZapFilter{MinLevel: "warn"}, errorLine, true},
+		{"plain with filter", ZapFilter{MinLevel: "warn"}, plain, false},
+		{"field string", ZapFilter{Fields: map[string]string{"ns": "/foo"}}, info, true},
+		{"field number", ZapFilter{Fields: map[string]string{"code": "404"}}, info, true},
+		{"field object", ZapFilter{Fields: map[string]string{"obj": `{"a":1}`}}, info, true},
+		{"field mismatch", ZapFilter{Fields: map[string]string{"ns": "/foo"}}, errorLine, false},
+		{"field missing", ZapFilter{Fields: map[string]string{"code": "404"}}, errorLine, false},
+		{"field and level", ZapFilter{MinLevel: "error", Fields: map[string]string{"ns": "/foo"}}, info, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(tt.entry); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestZapLine_pretty(t *testing.T) {
+
+	z, _ := ParseZapLine(`{"level":"warn","ts":1700000000,"caller":"cid/api.go:12","msg":"slow request","duration":"2s","url":"/issue?a=b c"}`)
+
+	if got, want := z.pretty(false), `WARN  cid/api.go:12 slow request duration=2s url="/issue?a=b c"`; got != want {
+		t.Errorf("pretty() = %q, want %q", got, want)
+	}
+
+	if got, want := z.pretty(true), "\x1b[33mWARN \x1b[0m cid/api.go:12 slow request duration=2s url=\"/issue?a=b c\""; got != want {
+		t.Errorf("pretty() = %q, want %q", got, want)
+	}
+}
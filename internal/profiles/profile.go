@@ -19,16 +19,33 @@ type Profiles struct {
 // Datasource represent a set of data source
 // for a given stack
 type Datasource struct {
-	LogsIndex                 int    `json:"logsIndex"`
-	MetricsIndex              int    `json:"metricsIndex"`
-	Name                      string `json:"name"`
-	TracesIndex               int    `json:"tracesIndex"`
-	TracesDataSourceName      string `json:"tracesDataSourceName"`
-	MonitoringCAPath          string `json:"monitoringCAPath"`
-	MonitoringCertPath        string `json:"monitoringCertPath"`
-	MonitoringCertKeyPath     string `json:"monitoringCertKeyPath"`
-	MonitoringCertKeyPassword string `json:"monitoringCertKeyPassword"`
-	MonitoringURL             string `json:"monitoringURL"`
+	LogsIndex                 int            `json:"logsIndex"`
+	MetricsIndex              int            `json:"metricsIndex"`
+	MetricsNamespaceLabel     string         `json:"metricsNamespaceLabel"`
+	Name                      string         `json:"name"`
+	TracesIndex               int            `json:"tracesIndex"`
+	TracesBackend             string         `json:"tracesBackend"`
+	TracesDataSourceName      string         `json:"tracesDataSourceName"`
+	MonitoringCAPath          string         `json:"monitoringCAPath"`
+	MonitoringCertPath        string         `json:"monitoringCertPath"`
+	MonitoringCertKeyPath     string         `json:"monitoringCertKeyPath"`
+	MonitoringCertKeyPassword string         `json:"monitoringCertKeyPassword"`
+	MonitoringURL             string         `json:"monitoringURL"`
+	ServiceMapping            ServiceMapping `json:"serviceMapping"`
+}
+
+// ServiceMapping maps the metrics service names
+// to the tracing service names
+type ServiceMapping struct {
+	Names map[string]string `json:"names"`
+	Rules []ServiceRule     `json:"rules"`
+}
+
+// ServiceRule rewrites the metrics service names matching
+// the pattern with the replacement (ex: ^(.*)-api$ -> $1)
+type ServiceRule struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
 }
 
 // PrintDatasources just list the datasources names
@@ -41,18 +58,79 @@ func (p Profiles) PrintDatasources() {
 
 }
 
+// StackNames returns the names of the stacks declared in the profile file,
+// or the default stack if there is no profile file
+func StackNames(profileFile string) ([]string, error) {
+
+	path, err := homedir.Expand(profileFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to expand the path %s: %w", profileFile, err)
+	}
+
+	p, err := parseProfile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read profile %s: %w", profileFile, err)
+	}
+
+	if p == nil {
+		return []string{"default"}, nil
+	}
+
+	names := make([]string, 0, len(p.Datasources))
+	for _, d := range p.Datasources {
+		names = append(names, d.Name)
+	}
+
+	return names, nil
+}
+
 // NewProfile will return a new profile using a profile file if exists
-// or the arguments if no profile file is set
+// or the arguments if no profile file is set. It exits listing the
+// datasources if the stack is not found.
 func NewProfile(cfg *configuration.Configuration) *Datasource {
 
+	d, p, err := findDatasource(cfg)
+	if err != nil {
+		zap.L().Fatal("Unable to load profile", zap.String("path", cfg.ProfileFile), zap.Error(err))
+	}
+
+	if d == nil {
+		zap.L().Error("Unable to find stack name in profile", zap.String("stack", cfg.Stack), zap.String("profile", cfg.ProfileFile))
+		p.PrintDatasources()
+		os.Exit(1)
+	}
+
+	return d
+}
+
+// FindDatasource returns the datasource of the stack like NewProfile,
+// with an error if the stack is not found
+func FindDatasource(cfg *configuration.Configuration) (*Datasource, error) {
+
+	d, _, err := findDatasource(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if d == nil {
+		return nil, fmt.Errorf("unable to find stack %s in profile %s", cfg.Stack, cfg.ProfileFile)
+	}
+
+	return d, nil
+}
+
+// findDatasource returns the datasource of the stack and the profiles it
+// was looked up in. The datasource is nil if the stack is not found.
+func findDatasource(cfg *configuration.Configuration) (*Datasource, *Profiles, error) {
+
 	path, err := homedir.Expand(cfg.ProfileFile)
 	if err != nil {
-		zap.L().Fatal("Unable to expand the path", zap.String("path", cfg.ProfileFile), zap.Error(err))
+		return nil, nil, fmt.Errorf("unable to expand the path %s: %w", cfg.ProfileFile, err)
 	}
 
 	p, err := parseProfile(path)
 	if err != nil {
-		zap.L().Fatal("Unable to read profile", zap.String("path", cfg.ProfileFile), zap.Error(err))
+		return nil, nil, fmt.Errorf("unable to read profile %s: %w", cfg.ProfileFile, err)
 	}
 
 	if (p == nil || cfg.MonitoringURL != "") && cfg.Stack == "default" {
@@ -71,6 +149,11 @@ func NewProfile(cfg *configuration.Configuration) *Datasource {
 		}}
 	}
 
+	// Without profile file, only the default stack exists
+	if p == nil {
+		p = &Profiles{}
+	}
+
 	for _, d := range p.Datasources {
 		if d.Name == cfg.Stack {
 
@@ -96,14 +179,19 @@ func NewProfile(cfg *configuration.Configuration) *Datasource {
 				d.MetricsIndex = 1
 			}
 
-			return &d
+			if d.TracesBackend == "" {
+				d.TracesBackend = "jaeger"
+			}
+
+			if d.MetricsNamespaceLabel == "" {
+				d.MetricsNamespaceLabel = "namespace"
+			}
+
+			return &d, p, nil
 		}
 	}
 
-	zap.L().Error("Unable to find stack name in profile", zap.String("stack", cfg.Stack), zap.String("profile", cfg.ProfileFile))
-	p.PrintDatasources()
-	os.Exit(1)
-	return nil
+	return nil, p, nil
 }
 
 // parseProfile will parse a yaml profile and return a Profile
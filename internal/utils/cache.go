@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CachedValues returns the values cached in the file at path if they are
+// younger than ttl, otherwise it fetches them and refreshes the cache.
+// The cache is best effort: failing to write it does not fail the call.
+func CachedValues(path string, ttl time.Duration, fetch func() ([]string, error)) ([]string, error) {
+
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < ttl {
+		if data, err := os.ReadFile(path); err == nil {
+			values := []string{}
+			if err := json.Unmarshal(data, &values); err == nil {
+				return values, nil
+			}
+		}
+	}
+
+	values, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(values); err == nil {
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err == nil {
+			_ = os.WriteFile(path, data, 0o600)
+		}
+	}
+
+	return values, nil
+}
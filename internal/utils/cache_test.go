@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCachedValues(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "tracer", "services.json")
+
+	calls := 0
+	fetch := func(values []string, err error) func() ([]string, error) {
+		return func() ([]string, error) {
+			calls++
+			return values, err
+		}
+	}
+
+	// Empty cache fetches and writes the values
+	got, err := CachedValues(path, time.Minute, fetch([]string{"cid", "squall"}, nil))
+	if err != nil {
+		t.Fatalf("CachedValues() error = %v", err)
+	}
+	if want := []string{"cid", "squall"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CachedValues() = %v, want %v", got, want)
+	}
+
+	// Fresh cache is used
+	got, err = CachedValues(path, time.Minute, fetch(nil, errors.New("unreachable")))
+	if err != nil {
+		t.Fatalf("CachedValues() error = %v", err)
+	}
+	if want := []string{"cid", "squall"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CachedValues() = %v, want %v", got, want)
+	}
+	if calls != 1 {
+		t.Errorf("CachedValues() fetched %d times, want 1", calls)
+	}
+
+	// Expired cache is refreshed
+	old := time.Now().Add(-2 * time.Minute)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CachedValues(path, time.Minute, fetch(nil, errors.New("unreachable"))); err == nil {
+		t.Errorf("CachedValues() expected the fetch error")
+	}
+
+	got, err = CachedValues(path, time.Minute, fetch([]string{"midgard"}, nil))
+	if err != nil {
+		t.Fatalf("CachedValues() error = %v", err)
+	}
+	if want := []string{"midgard"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CachedValues() = %v, want %v", got, want)
+	}
+	if calls != 3 {
+		t.Errorf("CachedValues() fetched %d times, want 3", calls)
+	}
+}
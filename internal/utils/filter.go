@@ -3,14 +3,38 @@ package utils
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/aporeto-inc/tracer/internal/monitoring"
+	"go.aporeto.io/elemental"
+	"go.aporeto.io/gaia"
 )
 
+// operations is the list of known elemental operations
+var operations = []elemental.Operation{
+	elemental.OperationCreate,
+	elemental.OperationDelete,
+	elemental.OperationInfo,
+	elemental.OperationPatch,
+	elemental.OperationRetrieve,
+	elemental.OperationRetrieveMany,
+	elemental.OperationUpdate,
+}
+
 // Filter is meant to filter APIErrors given filers
-func Filter(codes string, services, urls []string, results monitoring.APIErrors) (monitoring.APIErrors, error) {
+func Filter(codes string, services, urls, identities, ops []string, results monitoring.APIErrors) (monitoring.APIErrors, error) {
+
+	identityFilter, err := parseIdentities(identities)
+	if err != nil {
+		return nil, err
+	}
+
+	operationFilter, err := parseOperations(ops)
+	if err != nil {
+		return nil, err
+	}
 
 	serviceFilter := make(map[string]struct{})
 	for _, s := range services {
@@ -83,6 +107,24 @@ func Filter(codes string, services, urls []string, results monitoring.APIErrors)
 		}
 	}
 
+	// Remove the identities that are not matching
+	if len(identityFilter) > 0 {
+		for _, result := range results {
+			if _, ok := identityFilter[result.Identity]; !ok {
+				toRemove[result.Hash()] = struct{}{}
+			}
+		}
+	}
+
+	// Remove the operations that are not matching
+	if len(operationFilter) > 0 {
+		for _, result := range results {
+			if _, ok := operationFilter[result.Operation]; !ok {
+				toRemove[result.Hash()] = struct{}{}
+			}
+		}
+	}
+
 	for h := range toRemove {
 		delete(toFilter, h)
 	}
@@ -112,3 +154,98 @@ func Filter(codes string, services, urls []string, results monitoring.APIErrors)
 
 	return filtered, nil
 }
+
+// ParseIdentities validates the identities against gaia and returns
+// their sorted names. An identity can be given by name or by category.
+func ParseIdentities(identities []string) ([]string, error) {
+
+	filter, err := parseIdentities(identities)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(filter))
+	for name := range filter {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// parseIdentities validates the identities against gaia and returns
+// their names. An identity can be given by name or by category.
+func parseIdentities(identities []string) (map[string]struct{}, error) {
+
+	manager := gaia.Manager()
+	filter := make(map[string]struct{})
+
+	for _, i := range identities {
+
+		i = strings.ToLower(strings.TrimSpace(i))
+		if i == "" {
+			continue
+		}
+
+		identity := manager.IdentityFromName(i)
+		if identity.IsEmpty() {
+			identity = manager.IdentityFromCategory(i)
+		}
+
+		if identity.IsEmpty() {
+			candidates := []string{}
+			for _, known := range manager.AllIdentities() {
+				candidates = append(candidates, known.Name, known.Category)
+			}
+			return nil, fmt.Errorf("--identity failed to parse: Unknown identity: %s%s", i, didYouMean(i, candidates))
+		}
+
+		filter[identity.Name] = struct{}{}
+	}
+
+	return filter, nil
+}
+
+// parseOperations validates the operations against the elemental operations
+func parseOperations(ops []string) (map[string]struct{}, error) {
+
+	candidates := make([]string, len(operations))
+	for i, o := range operations {
+		candidates[i] = string(o)
+	}
+
+	filter := make(map[string]struct{})
+
+	for _, o := range ops {
+
+		o = strings.ToLower(strings.TrimSpace(o))
+		if o == "" {
+			continue
+		}
+
+		if !func() bool {
+			for _, c := range candidates {
+				if c == o {
+					return true
+				}
+			}
+			return false
+		}() {
+			return nil, fmt.Errorf("--elemental-operation failed to parse: Unknown operation: %s%s", o, didYouMean(o, candidates))
+		}
+
+		filter[o] = struct{}{}
+	}
+
+	return filter, nil
+}
+
+// didYouMean returns a suggestion hint for an unknown value if any
+func didYouMean(value string, candidates []string) string {
+
+	if s := Suggest(value, candidates); s != "" {
+		return fmt.Sprintf(", did you mean %s?", s)
+	}
+
+	return ""
+}
@@ -10,10 +10,12 @@ import (
 
 func TestFilter(t *testing.T) {
 	type args struct {
-		codes    string
-		services []string
-		urls     []string
-		results  monitoring.APIErrors
+		codes      string
+		services   []string
+		urls       []string
+		identities []string
+		operations []string
+		results    monitoring.APIErrors
 	}
 	tests := []struct {
 		name    string
@@ -202,10 +204,118 @@ func TestFilter(t *testing.T) {
 				}},
 			false,
 		},
+		{
+			"same template in different services",
+			args{
+				codes: "403",
+				results: monitoring.APIErrors{
+					monitoring.APIError{
+						Count:   1,
+						Code:    403,
+						Service: "squall",
+						Method:  "GET",
+						URL:     "/processingunits/:id",
+					},
+					monitoring.APIError{
+						Count:   2,
+						Code:    403,
+						Service: "wutai",
+						Method:  "GET",
+						URL:     "/processingunits/:id",
+					}},
+			},
+			monitoring.APIErrors{
+				monitoring.APIError{
+					Count:   1,
+					Code:    403,
+					Service: "squall",
+					Method:  "GET",
+					URL:     "/processingunits/:id",
+				},
+				monitoring.APIError{
+					Count:   2,
+					Code:    403,
+					Service: "wutai",
+					Method:  "GET",
+					URL:     "/processingunits/:id",
+				}},
+			false,
+		},
+		{
+			"fail unknown identity",
+			args{
+				identities: []string{"processingunitz"},
+			},
+			nil,
+			true,
+		},
+		{
+			"fail unknown operation",
+			args{
+				operations: []string{"retreive"},
+			},
+			nil,
+			true,
+		},
+		{
+			"filtering identities and operations works",
+			args{
+				identities: []string{"processingunits", "enforcer"},
+				operations: []string{"retrieve-many"},
+				results: monitoring.APIErrors{
+					monitoring.APIError{
+						Count:     0,
+						Code:      200,
+						Identity:  "processingunit",
+						Operation: "retrieve-many",
+						URL:       "/processingunits",
+					},
+					monitoring.APIError{
+						Count:     1,
+						Code:      200,
+						Identity:  "enforcer",
+						Method:    "GET",
+						Operation: "retrieve-many",
+						URL:       "/enforcers",
+					},
+					monitoring.APIError{
+						Count:     2,
+						Code:      200,
+						Identity:  "enforcer",
+						Method:    "POST",
+						Operation: "create",
+						URL:       "/enforcers",
+					},
+					monitoring.APIError{
+						Count:     3,
+						Code:      200,
+						Identity:  "namespace",
+						Operation: "retrieve-many",
+						URL:       "/namespaces",
+					}},
+			},
+			monitoring.APIErrors{
+				monitoring.APIError{
+					Count:     0,
+					Code:      200,
+					Identity:  "processingunit",
+					Operation: "retrieve-many",
+					URL:       "/processingunits",
+				},
+				monitoring.APIError{
+					Count:     1,
+					Code:      200,
+					Identity:  "enforcer",
+					Method:    "GET",
+					Operation: "retrieve-many",
+					URL:       "/enforcers",
+				}},
+			false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := Filter(tt.args.codes, tt.args.services, tt.args.urls, tt.args.results)
+			got, err := Filter(tt.args.codes, tt.args.services, tt.args.urls, tt.args.identities, tt.args.operations, tt.args.results)
 			// we sort it so it's concistent
 			sort.Sort(monitoring.ByCount(got))
 			if (err != nil) != tt.wantErr {
@@ -0,0 +1,67 @@
+package utils
+
+import "fmt"
+
+// Suggest returns the closest candidate to the given value
+// or an empty string if none of them is close enough.
+func Suggest(value string, candidates []string) string {
+
+	best := ""
+	bestDistance := len(value)/2 + 1
+
+	for _, c := range candidates {
+		if d := levenshtein(value, c); d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+
+	return best
+}
+
+// levenshtein computes the edit distance between two strings
+func levenshtein(a, b string) int {
+
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// CheckKnown returns an error for the first value that is not one of the
+// known values, suggesting the closest known one.
+func CheckKnown(kind string, values []string, known []string) error {
+
+	for _, v := range values {
+
+		found := false
+		for _, k := range known {
+			if k == v {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("Unknown %s: %s%s", kind, v, didYouMean(v, known))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package utils
+
+import "testing"
+
+func TestSuggest(t *testing.T) {
+	type args struct {
+		value      string
+		candidates []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"no candidates",
+			args{
+				value: "squall",
+			},
+			"",
+		},
+		{
+			"close match",
+			args{
+				value:      "squal",
+				candidates: []string{"cid", "squall", "midgard"},
+			},
+			"squall",
+		},
+		{
+			"too far",
+			args{
+				value:      "zack",
+				candidates: []string{"squall", "midgard"},
+			},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Suggest(tt.args.value, tt.args.candidates); got != tt.want {
+				t.Errorf("Suggest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckKnown(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		known   []string
+		wantErr string
+	}{
+		{
+			"all known",
+			[]string{"squall", "cid"},
+			[]string{"cid", "midgard", "squall"},
+			"",
+		},
+		{
+			"typo",
+			[]string{"cid", "squal"},
+			[]string{"cid", "midgard", "squall"},
+			"Unknown service: squal, did you mean squall?",
+		},
+		{
+			"nothing close",
+			[]string{"zack"},
+			[]string{"cid", "midgard", "squall"},
+			"Unknown service: zack",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckKnown("service", tt.values, tt.known)
+			if (err != nil || tt.wantErr != "") && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("CheckKnown() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// TimeFormats describes the time formats accepted by ParseTime
+const TimeFormats = `RFC3339 (2020-10-22T17:56:17Z), ` +
+	`a local time in --tz (2020-10-22 17:56:17, 2020-10-22T17:56, 2020-10-22 or 17:56 for today), ` +
+	`a unix epoch in s, ms, µs or ns (1603389377), ` +
+	`now, now-2h, now+5m or 10 minutes ago`
+
+// localLayouts are the layouts of the times without time zone,
+// the fractional seconds are always accepted by time.Parse
+var localLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// clockLayouts are the layouts of the times of the current day
+var clockLayouts = []string{
+	"15:04:05",
+	"15:04",
+}
+
+// zonedLayouts are the layouts of the times with a time zone
+var zonedLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05Z07:00",
+}
+
+// agoUnits are the units accepted in the "10 minutes ago" form
+var agoUnits = map[string]time.Duration{
+	"s":       time.Second,
+	"sec":     time.Second,
+	"secs":    time.Second,
+	"second":  time.Second,
+	"seconds": time.Second,
+	"m":       time.Minute,
+	"min":     time.Minute,
+	"mins":    time.Minute,
+	"minute":  time.Minute,
+	"minutes": time.Minute,
+	"h":       time.Hour,
+	"hour":    time.Hour,
+	"hours":   time.Hour,
+	"d":       24 * time.Hour,
+	"day":     24 * time.Hour,
+	"days":    24 * time.Hour,
+	"w":       7 * 24 * time.Hour,
+	"week":    7 * 24 * time.Hour,
+	"weeks":   7 * 24 * time.Hour,
+}
+
+var (
+	agoRegexp    = regexp.MustCompile(`^(\d+)\s*([a-z]+)\s+ago$`)
+	epochRegexp  = regexp.MustCompile(`^\d+(\.\d{1,9})?$`)
+	offsetRegexp = regexp.MustCompile(`^[+-]\d{2}:?\d{2}$`)
+)
+
+// ParseLocation parses a time zone: local, UTC, an IANA name
+// like Europe/Paris or an offset like +02:00
+func ParseLocation(tz string) (*time.Location, error) {
+
+	switch strings.ToLower(tz) {
+	case "", "local":
+		return time.Local, nil
+	case "utc", "z":
+		return time.UTC, nil
+	}
+
+	if offsetRegexp.MatchString(tz) {
+		t, err := time.Parse("-0700", strings.Replace(tz, ":", "", 1))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse time zone: %s is not a valid offset", tz)
+		}
+		_, offset := t.Zone()
+		return time.FixedZone(tz, offset), nil
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse time zone: %s must be local, UTC, a name like Europe/Paris or an offset like +02:00", tz)
+	}
+
+	return loc, nil
+}
+
+// ParseAround returns the window of the given duration centered on around
+func ParseAround(around string, window time.Duration, loc *time.Location) (fromTime time.Time, toTime time.Time, sinceDuration time.Duration, err error) {
+
+	at, err := parseTimeExpr(around, time.Now().Round(time.Second), loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, 0 * time.Second, fmt.Errorf("unable to parse around time: %s is not a valid time, use one of %s", around, TimeFormats)
+	}
+
+	if window <= 0 {
+		return time.Time{}, time.Time{}, 0 * time.Second, fmt.Errorf("unable to center the window: %s must be a positive duration", window)
+	}
+
+	return at.Add(-window / 2), at.Add(window / 2), window, nil
+}
+
+// parseTimeExpr parses a time in one of the TimeFormats, relative to now
+// and in the given location if it has no time zone.
+func parseTimeExpr(value string, now time.Time, loc *time.Location) (time.Time, error) {
+
+	value = strings.TrimSpace(value)
+	lower := strings.ToLower(value)
+
+	// Grafana style: now, now-2h, now+5m
+	if lower == "now" {
+		return now, nil
+	}
+	if strings.HasPrefix(lower, "now-") || strings.HasPrefix(lower, "now+") {
+		d, err := model.ParseDuration(lower[4:])
+		if err != nil {
+			return time.Time{}, err
+		}
+		if lower[3] == '-' {
+			return now.Add(-time.Duration(d)), nil
+		}
+		return now.Add(time.Duration(d)), nil
+	}
+
+	// Human style: 10 minutes ago, 2h ago
+	if m := agoRegexp.FindStringSubmatch(lower); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return time.Time{}, err
+		}
+		unit, ok := agoUnits[m[2]]
+		if !ok {
+			return time.Time{}, fmt.Errorf("unknown unit %s", m[2])
+		}
+		return now.Add(-time.Duration(n) * unit), nil
+	}
+
+	// Unix epochs, the unit is guessed from the magnitude
+	if epochRegexp.MatchString(value) {
+		return parseEpoch(value)
+	}
+
+	for _, layout := range zonedLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	for _, layout := range clockLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			y, mo, d := now.In(loc).Date()
+			return time.Date(y, mo, d, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unknown time format")
+}
+
+// parseEpoch parses a unix epoch in seconds (with optional decimals),
+// milliseconds, microseconds or nanoseconds
+func parseEpoch(value string) (time.Time, error) {
+
+	if sec, frac, ok := strings.Cut(value, "."); ok {
+		s, err := strconv.ParseInt(sec, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		ns, err := strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(s, ns), nil
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	switch {
+	case n < 1e11:
+		return time.Unix(n, 0), nil
+	case n < 1e14:
+		return time.UnixMilli(n), nil
+	case n < 1e17:
+		return time.UnixMicro(n), nil
+	default:
+		return time.Unix(0, n), nil
+	}
+}
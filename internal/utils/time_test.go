@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parseTimeExpr(t *testing.T) {
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	now := time.Date(2020, 10, 22, 17, 56, 17, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		value   string
+		loc     *time.Location
+		want    time.Time
+		wantErr bool
+	}{
+		{"rfc3339", "2020-10-22T17:56:17Z", time.UTC, now, false},
+		{"rfc3339 with offset", "2020-10-22T19:56:17+02:00", time.UTC, now, false},
+		{"rfc3339 nano", "2020-10-22T17:56:17.5Z", time.UTC, now.Add(500 * time.Millisecond), false},
+		{"local time", "2020-10-22 19:56:17", paris, now, false},
+		{"local time with T", "2020-10-22T19:56:17", paris, now, false},
+		{"local time with fraction", "2020-10-22 17:56:17.250", time.UTC, now.Add(250 * time.Millisecond), false},
+		{"local minutes", "2020-10-22 17:56", time.UTC, now.Add(-17 * time.Second), false},
+		{"local day", "2020-10-22", time.UTC, time.Date(2020, 10, 22, 0, 0, 0, 0, time.UTC), false},
+		{"clock today", "17:50", time.UTC, now.Add(-6*time.Minute - 17*time.Second), false},
+		{"clock today in zone", "19:56:17", paris, now, false},
+		{"now", "now", time.UTC, now, false},
+		{"now minus", "now-2h", time.UTC, now.Add(-2 * time.Hour), false},
+		{"now plus", "now+5m", time.UTC, now.Add(5 * time.Minute), false},
+		{"now minus days", "now-1d", time.UTC, now.Add(-24 * time.Hour), false},
+		{"ago", "10 minutes ago", time.UTC, now.Add(-10 * time.Minute), false},
+		{"ago singular", "1 hour ago", time.UTC, now.Add(-time.Hour), false},
+		{"ago short", "3d ago", time.UTC, now.Add(-72 * time.Hour), false},
+		{"epoch seconds", "1603389377", time.UTC, time.Unix(1603389377, 0), false},
+		{"epoch decimals", "1603389377.123", time.UTC, time.Unix(1603389377, 123000000), false},
+		{"epoch milliseconds", "1603389377123", time.UTC, time.Unix(1603389377, 123000000), false},
+		{"epoch microseconds", "1603389377123456", time.UTC, time.Unix(1603389377, 123456000), false},
+		{"epoch nanoseconds", "1603389377123456789", time.UTC, time.Unix(1603389377, 123456789), false},
+		{"invalid", "chien", time.UTC, time.Time{}, true},
+		{"invalid unit", "10 lightyears ago", time.UTC, time.Time{}, true},
+		{"invalid now", "now-2x", time.UTC, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTimeExpr(tt.value, now, tt.loc)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseTimeExpr() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseTimeExpr() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	tests := []struct {
+		name       string
+		tz         string
+		wantOffset int
+		wantErr    bool
+	}{
+		{"utc", "UTC", 0, false},
+		{"offset", "+02:00", 2 * 3600, false},
+		{"offset without colon", "-0530", -(5*3600 + 30*60), false},
+		{"unknown", "Mars/Olympus", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLocation(tt.tz)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseLocation() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if _, offset := time.Date(2020, 1, 1, 0, 0, 0, 0, got).Zone(); offset != tt.wantOffset {
+				t.Errorf("ParseLocation() offset = %d, want %d", offset, tt.wantOffset)
+			}
+		})
+	}
+
+	if loc, err := ParseLocation("local"); err != nil || loc != time.Local {
+		t.Errorf("ParseLocation(local) = %v, %v, want the local time zone", loc, err)
+	}
+}
+
+func TestParseAround(t *testing.T) {
+
+	from, to, since, err := ParseAround("2020-10-22T17:56:17Z", 10*time.Minute, time.UTC)
+	if err != nil {
+		t.Fatalf("ParseAround() error = %v", err)
+	}
+	if want := time.Date(2020, 10, 22, 17, 51, 17, 0, time.UTC); !from.Equal(want) {
+		t.Errorf("ParseAround() from = %v, want %v", from, want)
+	}
+	if want := time.Date(2020, 10, 22, 18, 1, 17, 0, time.UTC); !to.Equal(want) {
+		t.Errorf("ParseAround() to = %v, want %v", to, want)
+	}
+	if since != 10*time.Minute {
+		t.Errorf("ParseAround() since = %v, want %v", since, 10*time.Minute)
+	}
+
+	if _, _, _, err := ParseAround("2020-10-22T17:56:17Z", 0, time.UTC); err == nil {
+		t.Errorf("ParseAround() expected an error for an empty window")
+	}
+
+	_, _, _, err = ParseAround("chien", 10*time.Minute, time.UTC)
+	if err == nil || !strings.Contains(err.Error(), TimeFormats) {
+		t.Errorf("ParseAround() error = %v, want the accepted formats", err)
+	}
+}
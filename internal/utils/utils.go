@@ -3,9 +3,11 @@ package utils
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/olekukonko/tablewriter"
+	"github.com/prometheus/common/model"
 )
 
 // Tabulate print a table from data
@@ -33,27 +35,30 @@ func Tabulate(headers []string, rows [][]string) string {
 	return "\n" + out.String()
 }
 
-// ParseTime is a function to parse time from to and since
-func ParseTime(from string, to string, since time.Duration) (fromTime time.Time, toTime time.Time, sinceDuration time.Duration, err error) {
+// ParseTime is a function to parse time from to and since, the times
+// without time zone are in loc. See TimeFormats for the accepted formats.
+func ParseTime(from string, to string, since time.Duration, loc *time.Location) (fromTime time.Time, toTime time.Time, sinceDuration time.Duration, err error) {
+
+	now := time.Now().Round(time.Second)
 
 	// Parse time if set
 	if from != "" {
-		fromTime, err = time.Parse(time.RFC3339, from)
+		fromTime, err = parseTimeExpr(from, now, loc)
 		if err != nil {
-			return time.Time{}, time.Time{}, 0 * time.Second, fmt.Errorf("unable to parse from duration: %s is not a valid time", from)
+			return time.Time{}, time.Time{}, 0 * time.Second, fmt.Errorf("unable to parse from time: %s is not a valid time, use one of %s", from, TimeFormats)
 		}
 	}
 
 	if to != "" {
-		toTime, err = time.Parse(time.RFC3339, to)
+		toTime, err = parseTimeExpr(to, now, loc)
 		if err != nil {
-			return time.Time{}, time.Time{}, 0 * time.Second, fmt.Errorf("unable to parse to duration: %s is not a valid time", to)
+			return time.Time{}, time.Time{}, 0 * time.Second, fmt.Errorf("unable to parse to time: %s is not a valid time, use one of %s", to, TimeFormats)
 		}
 	}
 
 	// If to time is not set make it now
 	if toTime.IsZero() {
-		toTime = time.Now().Round(time.Second)
+		toTime = now
 	}
 
 	// If a from is set compute since
@@ -65,3 +70,35 @@ func ParseTime(from string, to string, since time.Duration) (fromTime time.Time,
 	return toTime.Add(-since), toTime, since, nil
 
 }
+
+// ParseDurations parses a list of durations, the units d, w and y are supported
+func ParseDurations(values []string) ([]time.Duration, error) {
+
+	durations := make([]time.Duration, 0, len(values))
+
+	for _, v := range values {
+		d, err := model.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse duration: %s is not a valid duration", v)
+		}
+		durations = append(durations, time.Duration(d))
+	}
+
+	return durations, nil
+}
+
+// ParseTags parses a list of key=value tags
+func ParseTags(values []string) (map[string]string, error) {
+
+	tags := make(map[string]string, len(values))
+
+	for _, v := range values {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("unable to parse tag: %s must be in the form key=value", v)
+		}
+		tags[strings.TrimSpace(key)] = value
+	}
+
+	return tags, nil
+}
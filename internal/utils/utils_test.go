@@ -91,6 +91,18 @@ func TestParseTime(t *testing.T) {
 			1 * time.Second,
 			false,
 		},
+		{
+			"Parse epoch from and local to",
+			args{
+				from:  "1603389376",
+				to:    "2020-10-22 17:56:17",
+				since: time.Second,
+			},
+			time.Unix(1603389376, 0),
+			time.Date(2020, 10, 22, 17, 56, 17, 0, time.UTC),
+			1 * time.Second,
+			false,
+		},
 		{
 			"Parse since and from",
 			args{
@@ -117,7 +129,7 @@ func TestParseTime(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotFromTime, gotToTime, gotSinceDuration, err := ParseTime(tt.args.from, tt.args.to, tt.args.since)
+			gotFromTime, gotToTime, gotSinceDuration, err := ParseTime(tt.args.from, tt.args.to, tt.args.since, time.UTC)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ParseTime() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -134,3 +146,53 @@ func TestParseTime(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			"no tags",
+			nil,
+			map[string]string{},
+			false,
+		},
+		{
+			"invalid tag",
+			[]string{"chien"},
+			nil,
+			true,
+		},
+		{
+			"empty key",
+			[]string{"=chien"},
+			nil,
+			true,
+		},
+		{
+			"valid tags",
+			[]string{"http.method=GET", "req.namespace=/foo=bar", "empty="},
+			map[string]string{
+				"http.method":   "GET",
+				"req.namespace": "/foo=bar",
+				"empty":         "",
+			},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTags(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseTags() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
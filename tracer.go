@@ -3,7 +3,11 @@ package main
 
 import (
 	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -12,6 +16,7 @@ import (
 	"github.com/aporeto-inc/tracer/internal/monitoring"
 	"github.com/aporeto-inc/tracer/internal/profiles"
 	"github.com/aporeto-inc/tracer/internal/utils"
+	"github.com/spf13/pflag"
 	"go.uber.org/zap"
 )
 
@@ -19,16 +24,50 @@ import (
 func main() {
 
 	cfg := configuration.NewConfiguration()
+	args := pflag.Args()
+
+	// Generate the completion scripts if asked
+	if len(args) == 2 && args[0] == "completion" {
+		script, err := configuration.CompletionScript(args[1])
+		if err != nil {
+			zap.L().Fatal("Unable to generate completion script", zap.Error(err))
+		}
+		fmt.Print(script)
+		return
+	}
+
+	// Complete the values of a flag for the completion scripts
+	if len(args) >= 2 && args[0] == configuration.CompleteCommand {
+		values, err := completeValues(cfg, args[1])
+		if err != nil {
+			zap.L().Fatal("Unable to complete values", zap.String("flag", args[1]), zap.Error(err))
+		}
+		fmt.Println(strings.Join(values, "\n"))
+		return
+	}
+
 	datasource := profiles.NewProfile(cfg)
 
 	if cfg.Open != "" {
-		monitoring.OpenTrace(datasource.MonitoringURL, datasource.TracesDataSourceName, cfg.Open)
+		monitoring.OpenTrace(datasource.MonitoringURL, datasource.TracesDataSourceName, datasource.TracesBackend, cfg.Open)
+	}
+
+	loc, err := utils.ParseLocation(cfg.TZ)
+	if err != nil {
+		zap.L().Fatal("Unable to parse time zone", zap.Error(err))
 	}
 
-	var err error
 	var from, to time.Time
+	var since time.Duration
 
-	from, to, since, err := utils.ParseTime(cfg.From, cfg.To, cfg.Since)
+	if cfg.Around != "" {
+		if cfg.From != "" || cfg.To != "" {
+			zap.L().Fatal("Unable to parse time", zap.Error(fmt.Errorf("--around cannot be used with --from or --to")))
+		}
+		from, to, since, err = utils.ParseAround(cfg.Around, cfg.Window, loc)
+	} else {
+		from, to, since, err = utils.ParseTime(cfg.From, cfg.To, cfg.Since, loc)
+	}
 	if err != nil {
 		zap.L().Fatal("Unable to parse time", zap.Error(err))
 	}
@@ -44,84 +83,292 @@ func main() {
 		zap.L().Fatal("Unable to connect to monitoring", zap.Error(err))
 	}
 
+	// Select the tracing backend
+	c.SetRateLimit(datasource.TracesIndex, cfg.RateLimit)
+	c.SetConcurrency(cfg.Concurrency)
+	backend, err := c.TraceBackend(datasource.TracesIndex)
+	if err != nil {
+		zap.L().Fatal("Unable to create tracing backend", zap.Error(err))
+	}
+
+	// Parse the trace tags
+	tags, err := utils.ParseTags(cfg.Tags)
+	if err != nil {
+		zap.L().Fatal("Failed to parse tags", zap.Error(err))
+	}
+
+	// An empty duration range would silently return no traces
+	if cfg.MinDuration > 0 && cfg.MaxDuration > 0 && cfg.MinDuration >= cfg.MaxDuration {
+		zap.L().Fatal("Invalid duration range: --slower-than must be lower than --faster-than", zap.Duration("slower-than", cfg.MinDuration), zap.Duration("faster-than", cfg.MaxDuration))
+	}
+
+	switch {
+
+	// Search traces if asked
+	case len(args) == 2 && args[0] == "traces" && args[1] == "search":
+
+		warnRecursiveTraces(cfg)
+
+		if err := searchTraces(backend, cfg, tracingParameters(cfg, from, to, tags)); err != nil {
+			zap.L().Fatal("Unable to search traces", zap.Error(err))
+		}
+
+	// Export traces if asked
+	case len(args) > 2 && args[0] == "trace" && args[1] == "export":
+
+		if err := exportTraces(backend, cfg, args[2:]); err != nil {
+			zap.L().Fatal("Unable to export traces", zap.Error(err))
+		}
+
+	// Diff traces if asked
+	case len(args) == 4 && args[0] == "trace" && args[1] == "diff":
+
+		if err := diffTraces(backend, args[2], args[3]); err != nil {
+			zap.L().Fatal("Unable to diff traces", zap.Error(err))
+		}
+
+	// Build the service graph if asked
+	case len(args) == 1 && args[0] == "graph":
+
+		if err := serviceGraph(backend, cfg, from, to, tags); err != nil {
+			zap.L().Fatal("Unable to build service graph", zap.Error(err))
+		}
+
+	// List the labels if asked
+	case len(args) >= 1 && args[0] == "labels":
+
+		if err := listLabels(c, datasource, from, to, args[1:]); err != nil {
+			zap.L().Fatal("Unable to list labels", zap.Error(err))
+		}
+
+	// Show log stats if asked
+	case len(args) == 2 && args[0] == "logs" && args[1] == "stats":
+
+		if err := logStats(c, datasource.LogsIndex, from, to, cfg); err != nil {
+			zap.L().Fatal("Unable to get log stats", zap.Error(err))
+		}
+
 	// Show log if asked
-	if cfg.Log || cfg.LogFilter != "" {
+	case cfg.Log || cfg.LogFilter != "" || cfg.Patterns || cfg.LogDir != "":
+
+		fields, err := utils.ParseTags(cfg.Fields)
+		if err != nil {
+			zap.L().Fatal("Failed to parse fields", zap.Error(err))
+		}
 
-		quiet := true
-		if cfg.LogLevel == "debug" {
-			quiet = false
+		query, err := logQuery(cfg)
+		if err != nil {
+			zap.L().Fatal("Failed to build log query", zap.Error(err))
 		}
 
-		if err := c.GetLogs(datasource.LogsIndex, from, to, cfg.Services, cfg.LogConf, quiet); err != nil {
+		if cfg.ShowQuery {
+			fmt.Fprintln(os.Stderr, query)
+		}
+
+		// The log directory is written by pages of --lines only if it was set,
+		// the default number of lines to print is too small for a page
+		if cfg.LogDir != "" && !pflag.CommandLine.Changed("lines") {
+			cfg.LogLines = 0
+		}
+
+		if len(cfg.Services) > 0 {
+			checkServices(cfg.Services, func() ([]string, error) {
+				return c.Loki(datasource.LogsIndex).LabelValues("app", from, to)
+			})
+		}
+
+		if cfg.Patterns {
+			if err := logPatterns(c, datasource.LogsIndex, from, to, query, fields, cfg); err != nil {
+				zap.L().Fatal("Unable to get log patterns", zap.Error(err))
+			}
+			break
+		}
+
+		if err := c.GetLogs(datasource.LogsIndex, from, to, query, fields, cfg.LogConf); err != nil {
 			zap.L().Fatal("Unable to get logs", zap.Error(err))
 		}
 
-	} else {
+	default:
+
+		// Check if the namespace can be applied on the metrics
+		namespaced := false
+		metricsNamespace := ""
+		if cfg.Namespace != "" {
+			namespaced, err = c.SupportsNamespace(datasource.MetricsIndex, to)
+			if err != nil {
+				zap.L().Fatal("Unable to query prometheus", zap.Error(err))
+			}
+			switch {
+			case namespaced:
+				metricsNamespace = cfg.Namespace
+			case cfg.Recursive:
+				zap.L().Fatal("Unable to filter on the namespace children: the metrics do not carry the namespace label and the traces can only be matched on the exact namespace, remove --recursive",
+					zap.String("label", datasource.MetricsNamespaceLabel),
+					zap.String("namespace", cfg.Namespace),
+				)
+			default:
+				zap.L().Warn("Metrics do not carry the namespace label, falling back to the exact req.namespace tag of the traces: counts will be sampled from traces",
+					zap.String("label", datasource.MetricsNamespaceLabel),
+					zap.String("namespace", cfg.Namespace),
+				)
+			}
+		}
 
 		var results monitoring.APIErrors
 		// Get the metrics
-		results, err = c.GetAPIErrors(datasource.MetricsIndex, since, to)
+		results, err = c.GetAPIErrors(datasource.MetricsIndex, since, to, metricsNamespace, cfg.Recursive)
 		if err != nil {
 			zap.L().Fatal("Unable to query prometheus", zap.Error(err))
 		}
 
 		// Filter
-		results, err = utils.Filter(cfg.Codes, cfg.Services, cfg.URLS, results)
+		results, err = utils.Filter(cfg.Codes, cfg.Services, cfg.URLS, cfg.Identities, cfg.Operations, results)
 		if err != nil {
 			zap.L().Fatal("Failed to parse filters", zap.Error(err))
 		}
 
+		if len(cfg.Services) > 0 {
+			checkServices(cfg.Services, func() ([]string, error) {
+				return c.MetricLabelValues(datasource.MetricsIndex, "service", from, to)
+			})
+		}
+
+		// Score the anomalies
+		if cfg.Anomalies || cfg.AnomaliesOnly {
+
+			baselines := cfg.Baselines
+			if len(baselines) == 0 {
+				baselines = []string{"1d", "7d"}
+			}
+
+			offsets, err := utils.ParseDurations(baselines)
+			if err != nil {
+				zap.L().Fatal("Failed to parse baselines", zap.Error(err))
+			}
+
+			if err := c.ScoreAnomalies(datasource.MetricsIndex, since, to, metricsNamespace, cfg.Recursive, offsets, results); err != nil {
+				zap.L().Fatal("Unable to score anomalies", zap.Error(err))
+			}
+
+			if cfg.AnomaliesOnly {
+				results = func() monitoring.APIErrors {
+					res := monitoring.APIErrors{}
+					for _, item := range results {
+						if item.Anomaly.Flagged {
+							res = append(res, item)
+						}
+					}
+					return res
+				}()
+			}
+		}
+
 		// Sort by counts
 		sort.Sort(monitoring.ByCount(results))
 
-		// Get the traces
-		var wg sync.WaitGroup
-		wg.Add(len(results))
-
-		for i := range results {
-			go func(index int) {
-				defer wg.Done()
-
-				params := monitoring.TracingQueryParameters{
-					Start:       from.UnixNano() / 1000,
-					End:         to.UnixNano() / 1000,
-					Limit:       cfg.Limit,
-					MinDuration: cfg.MinDuration,
-					Service:     strings.Split(results[index].Service, "-")[0],
-					Tags: map[string]string{
-						"status.code":   fmt.Sprintf("%d", results[index].Code),
-						"req.identity":  results[index].Identity,
-						"req.operation": results[index].Operation,
-					},
-				}
+		// Learn the tracing services to map the metrics services
+		known, err := backend.ListServices()
+		if err != nil {
+			zap.L().Warn("Unable to list tracing services, falling back to service name prefix", zap.Error(err))
+		}
 
-				if cfg.OnlyError {
-					params.Tags["error"] = "true"
-				}
+		mapper, err := monitoring.NewServiceMapper(datasource.ServiceMapping, known)
+		if err != nil {
+			zap.L().Fatal("Unable to parse service mapping", zap.Error(err))
+		}
 
-				if cfg.Namespace != "" {
-					params.Tags["req.namespace"] = cfg.Namespace
-				}
+		// The progress line is only displayed on a terminal
+		progress := monitoring.IsTerminal(os.Stderr)
 
-				traceResults, err := c.GetTraceIDs(datasource.TracesIndex, params)
-				if err != nil {
-					zap.L().Error("Failed to retrieve traces for error", zap.Error(err))
-					return
+		// Get the traces with a bounded pool of workers
+		var (
+			lock      sync.Mutex
+			done      int
+			failed    []int
+			unmatched = map[string]struct{}{}
+		)
+
+		lookup := func(index int) {
+
+			defer func() {
+				lock.Lock()
+				defer lock.Unlock()
+				done++
+				if progress {
+					fmt.Fprintf(os.Stderr, "\rFetching traces: %d/%d (%d failed)", done, len(results), len(failed))
 				}
+			}()
+
+			service, ok := mapper.Resolve(results[index].Service)
+			if !ok {
+				results[index].Warnings = append(results[index].Warnings, fmt.Sprintf("no tracing service matching %s", results[index].Service))
+				lock.Lock()
+				unmatched[results[index].Service] = struct{}{}
+				lock.Unlock()
+				return
+			}
+
+			params := tracingParameters(cfg, from, to, tags)
+			params.Service = service
+			params.Tags["status.code"] = fmt.Sprintf("%d", results[index].Code)
+			params.Tags["req.identity"] = results[index].Identity
+			params.Tags["req.operation"] = results[index].Operation
 
-				results[index].Traces = traceResults
-			}(i)
+			traces, err := backend.SearchTraces(params)
+			if err != nil {
+				zap.L().Debug("Failed to retrieve traces for error", zap.Error(err))
+				results[index].TraceError = err
+				lock.Lock()
+				failed = append(failed, index)
+				lock.Unlock()
+				return
+			}
+
+			results[index].Traces = make([]string, len(traces))
+			for j, t := range traces {
+				results[index].Traces[j] = t.TraceID
+			}
+			results[index].Latency = monitoring.ComputeLatency(traces)
+			results[index].Errors = monitoring.SummarizeErrors(traces)
 		}
 
-		wg.Wait()
+		monitoring.ForEach(len(results), cfg.Concurrency, lookup)
 
-		// If we have a trace filter remove the entries without traces
-		if cfg.OnlyError || cfg.MinDuration.String() != "0s" || cfg.Namespace != "" {
+		if progress && len(results) > 0 {
+			fmt.Fprintln(os.Stderr)
+		}
+
+		if len(unmatched) > 0 {
+			services := make([]string, 0, len(unmatched))
+			for service := range unmatched {
+				services = append(services, service)
+			}
+			sort.Strings(services)
+			zap.L().Warn("Some services have no tracing service, add them to the serviceMapping of the profile", zap.Strings("services", services))
+		}
+
+		if len(failed) > 0 {
+			sort.Ints(failed)
+			fmt.Fprintf(os.Stderr, "\n> Trace lookup failed for %d rows:\n", len(failed))
+			for _, i := range failed {
+				fmt.Fprintf(os.Stderr, "  - %s %s %d: %s\n", results[i].Service, results[i].URL, results[i].Code, results[i].TraceError)
+			}
+		}
+
+		// If we have a trace filter that cannot be applied on the metrics
+		// remove the entries without traces and sample the count from them
+		if cfg.OnlyError || cfg.MinDuration != 0 || cfg.MaxDuration != 0 || cfg.SpanOperation != "" || len(tags) > 0 || (cfg.Namespace != "" && !namespaced) {
 			results = func() monitoring.APIErrors {
 				res := monitoring.APIErrors{}
 				for _, item := range results {
+					// Keep the failed lookups with their metrics count so they are visible
+					if item.TraceError != nil {
+						res = append(res, item)
+						continue
+					}
 					if len(item.Traces) != 0 {
 						item.Count = len(item.Traces)
+						item.Source = monitoring.CountSourceTraces
 						res = append(res, item)
 					}
 				}
@@ -132,16 +379,627 @@ func main() {
 		// Display
 		if len(results) > 0 {
 
-			fmt.Println(utils.Tabulate([]string{"count", "service", "identity", "operation", "url", "code", fmt.Sprintf("traces (limit=%d)", cfg.Limit)}, func() [][]string {
+			anomalies := cfg.Anomalies || cfg.AnomaliesOnly
+
+			headers := []string{"count", "source", "service", "identity", "operation", "url", "code"}
+			if anomalies {
+				headers = append(headers, "baseline", "anomaly")
+			}
+			headers = append(headers, "latency (min/avg/p95/max)", "slowest", fmt.Sprintf("traces (limit=%d)", cfg.Limit))
+
+			hasErrors := false
+			for _, i := range results {
+				hasErrors = hasErrors || i.Errors != nil
+			}
+			if hasErrors {
+				headers = append(headers, "error")
+			}
+
+			warnings := false
+			for i := range results {
+				if results[i].TraceError != nil {
+					results[i].Warnings = append(results[i].Warnings, "trace lookup failed")
+				}
+				warnings = warnings || len(results[i].Warnings) > 0
+			}
+			if warnings {
+				headers = append(headers, "warnings")
+			}
+
+			fmt.Println(utils.Tabulate(headers, func() [][]string {
 				r := [][]string{}
 				for _, i := range results {
-					r = append(r, []string{fmt.Sprintf("%d", i.Count), i.Service, i.Identity, i.Operation, i.URL, fmt.Sprintf("%d", i.Code), strings.Join(i.Traces, ",")})
+					row := []string{fmt.Sprintf("%d", i.Count), string(i.Source), i.Service, i.Identity, i.Operation, i.URL, fmt.Sprintf("%d", i.Code)}
+					if anomalies {
+						row = append(row, fmt.Sprintf("%.1f", i.Anomaly.Mean), i.Anomaly.String())
+					}
+					slowest := ""
+					if i.Latency != nil {
+						slowest = i.Latency.Slowest
+					}
+					row = append(row, i.Latency.String(), slowest, strings.Join(i.Traces, ","))
+					if hasErrors {
+						row = append(row, i.Errors.String())
+					}
+					if warnings {
+						row = append(row, strings.Join(i.Warnings, ", "))
+					}
+					r = append(r, row)
 				}
 				return r
 			}()))
 
-			fmt.Printf("\n> %d results found. You can read the traces from %s/explore and select the jaeger datasource.\n", len(results), cfg.MonitoringURL)
+			fmt.Printf("\n> %d results found. You can read the traces from %s/explore and select the %s datasource.\n", len(results), datasource.MonitoringURL, datasource.TracesBackend)
 			fmt.Println("  Or run tracer [--stack <name>] --open <trace>.")
 		}
 	}
 }
+
+// tracingParameters returns the tracing query parameters common to all trace searches
+func tracingParameters(cfg *configuration.Configuration, from, to time.Time, tags map[string]string) monitoring.TracingQueryParameters {
+
+	params := monitoring.TracingQueryParameters{
+		Start:       from.UnixNano() / 1000,
+		End:         to.UnixNano() / 1000,
+		Limit:       cfg.Limit,
+		MinDuration: cfg.MinDuration,
+		MaxDuration: cfg.MaxDuration,
+		Operation:   cfg.SpanOperation,
+		Tags:        monitoring.Tags{},
+	}
+
+	for k, v := range tags {
+		params.Tags[k] = v
+	}
+
+	if cfg.OnlyError {
+		params.Tags["error"] = "true"
+	}
+
+	// Traces can only be matched on the exact namespace, see warnRecursiveTraces
+	if cfg.Namespace != "" && !cfg.Recursive {
+		params.Tags["req.namespace"] = cfg.Namespace
+	}
+
+	return params
+}
+
+// warnRecursiveTraces warns that the namespace is not applied on the
+// traces with --recursive as they can only be matched on the exact namespace
+func warnRecursiveTraces(cfg *configuration.Configuration) {
+
+	if cfg.Namespace != "" && cfg.Recursive {
+		zap.L().Warn("Traces can only be matched on the exact namespace, the namespace filter is not applied with --recursive", zap.String("namespace", cfg.Namespace))
+	}
+}
+
+// serviceCacheTTL is how long the service names are cached for the completion
+const serviceCacheTTL = 10 * time.Minute
+
+// completeValues returns the values of a flag for the completion scripts:
+// the stacks of the profile file or the services seen in the last day
+func completeValues(cfg *configuration.Configuration, flag string) ([]string, error) {
+
+	switch flag {
+
+	case "stack":
+		return profiles.StackNames(cfg.ProfileFile)
+
+	case "service":
+
+		datasource, err := profiles.FindDatasource(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("unable to find the cache directory: %w", err)
+		}
+
+		// The same stack name can point to different monitoring urls
+		h := fnv.New32a()
+		h.Write([]byte(datasource.MonitoringURL)) // nolint
+		cache := fmt.Sprintf("services-%s-%08x.json", datasource.Name, h.Sum32())
+
+		return utils.CachedValues(filepath.Join(cacheDir, "tracer", cache), serviceCacheTTL, func() ([]string, error) {
+
+			c, err := monitoring.NewClient(datasource)
+			if err != nil {
+				return nil, err
+			}
+
+			to := time.Now()
+			from := to.Add(-24 * time.Hour)
+
+			metrics, metricsErr := c.MetricLabelValues(datasource.MetricsIndex, "service", from, to)
+			logs, logsErr := c.Loki(datasource.LogsIndex).LabelValues("app", from, to)
+			if metricsErr != nil && logsErr != nil {
+				return nil, fmt.Errorf("%w, %w", metricsErr, logsErr)
+			}
+
+			seen := map[string]bool{}
+			services := []string{}
+			for _, s := range append(metrics, logs...) {
+				if !seen[s] {
+					seen[s] = true
+					services = append(services, s)
+				}
+			}
+			sort.Strings(services)
+
+			return services, nil
+		})
+
+	default:
+		return nil, fmt.Errorf("no dynamic values for --%s", flag)
+	}
+}
+
+// checkServices warns about the services unknown to a datasource in the time window
+func checkServices(services []string, known func() ([]string, error)) {
+
+	values, err := known()
+	if err != nil {
+		zap.L().Debug("Unable to list the known services", zap.Error(err))
+		return
+	}
+
+	if err := utils.CheckKnown("service", services, values); err != nil {
+		zap.L().Warn("Service not found in the time window", zap.Error(err))
+	}
+}
+
+// listLabels displays the label names known by the metrics and the logs in
+// the time window, or the values of the given labels
+func listLabels(c *monitoring.Client, datasource *profiles.Datasource, from, to time.Time, names []string) error {
+
+	loki := c.Loki(datasource.LogsIndex)
+
+	// sources returns the values of a label, or the label names if empty, per datasource
+	sources := func(label string) (metrics []string, logs []string, err error) {
+
+		var metricsErr, logsErr error
+		if label == "" {
+			metrics, metricsErr = c.MetricLabelNames(datasource.MetricsIndex, from, to)
+			logs, logsErr = loki.LabelNames(from, to)
+		} else {
+			metrics, metricsErr = c.MetricLabelValues(datasource.MetricsIndex, label, from, to)
+			logs, logsErr = loki.LabelValues(label, from, to)
+		}
+
+		if metricsErr != nil && logsErr != nil {
+			return nil, nil, fmt.Errorf("%w, %w", metricsErr, logsErr)
+		}
+		if metricsErr != nil {
+			zap.L().Warn("Unable to list metric labels", zap.Error(metricsErr))
+		}
+		if logsErr != nil {
+			zap.L().Warn("Unable to list log labels", zap.Error(logsErr))
+		}
+
+		return metrics, logs, nil
+	}
+
+	// rows merges the values of both datasources, marking where they come from
+	rows := func(prefix []string, metrics []string, logs []string) [][]string {
+
+		marks := map[string][2]string{}
+		for _, v := range metrics {
+			m := marks[v]
+			m[0] = "x"
+			marks[v] = m
+		}
+		for _, v := range logs {
+			m := marks[v]
+			m[1] = "x"
+			marks[v] = m
+		}
+
+		values := make([]string, 0, len(marks))
+		for v := range marks {
+			values = append(values, v)
+		}
+		sort.Strings(values)
+
+		r := [][]string{}
+		for _, v := range values {
+			r = append(r, append(append([]string{}, prefix...), v, marks[v][0], marks[v][1]))
+		}
+
+		return r
+	}
+
+	if len(names) == 0 {
+
+		metrics, logs, err := sources("")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(utils.Tabulate([]string{"label", "metrics", "logs"}, rows(nil, metrics, logs)))
+
+		return nil
+	}
+
+	r := [][]string{}
+	for _, name := range names {
+
+		metrics, logs, err := sources(name)
+		if err != nil {
+			return err
+		}
+
+		r = append(r, rows([]string{name}, metrics, logs)...)
+	}
+
+	fmt.Println(utils.Tabulate([]string{"label", "value", "metrics", "logs"}, r))
+
+	return nil
+}
+
+// logSelector returns the selection of the logs from the flags
+func logSelector(cfg *configuration.Configuration) (monitoring.LogSelector, error) {
+
+	jsonFields, err := utils.ParseTags(cfg.JSONFields)
+	if err != nil {
+		return monitoring.LogSelector{}, fmt.Errorf("unable to parse json fields: %w", err)
+	}
+
+	return monitoring.LogSelector{
+		Services:   cfg.Services,
+		Pods:       cfg.Pods,
+		Containers: cfg.Containers,
+		Contains:   cfg.Contains,
+		Regexps:    cfg.Regexps,
+		Excludes:   cfg.Excludes,
+		JSONFields: jsonFields,
+		Filter:     cfg.LogFilter,
+	}, nil
+}
+
+// logQuery returns the LogQL query selecting the logs
+func logQuery(cfg *configuration.Configuration) (string, error) {
+
+	selector, err := logSelector(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	return selector.LogQL()
+}
+
+// logStats displays the series of a LogQL metric query with a sparkline per series
+func logStats(c *monitoring.Client, proxy int, from, to time.Time, cfg *configuration.Configuration) error {
+
+	query, top := cfg.StatsQuery, 0
+	if query == "" {
+
+		selector, err := logSelector(cfg)
+		if err != nil {
+			return err
+		}
+
+		if query, err = monitoring.ErrorVolumeQuery(selector, cfg.Step); err != nil {
+			return err
+		}
+		top = cfg.Top
+	}
+
+	if cfg.ShowQuery {
+		fmt.Fprintln(os.Stderr, query)
+	}
+
+	series, err := c.Loki(proxy).QueryMetric(query, from, to, cfg.Step)
+	if err != nil {
+		return err
+	}
+
+	if len(series) == 0 {
+		fmt.Println("No series found.")
+		return nil
+	}
+
+	// The series are sorted by total, so these are the top ones over the whole window
+	if top > 0 && len(series) > top {
+		series = series[:top]
+	}
+
+	// One column per label of the series
+	names := []string{}
+	seen := map[string]struct{}{}
+	for _, s := range series {
+		for name := range s.Labels {
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	headers := append(append([]string{}, names...), "total", "max", fmt.Sprintf("%s - %s (step=%s)", from.Format(time.RFC3339), to.Format(time.RFC3339), cfg.Step))
+
+	fmt.Println(utils.Tabulate(headers, func() [][]string {
+		r := [][]string{}
+		for _, s := range series {
+			row := []string{}
+			for _, name := range names {
+				row = append(row, s.Labels[name])
+			}
+			row = append(row, strconv.FormatFloat(s.Total(), 'f', -1, 64), strconv.FormatFloat(s.Max(), 'f', -1, 64), s.Sparkline())
+			r = append(r, row)
+		}
+		return r
+	}()))
+
+	return nil
+}
+
+// logPatterns displays the templates of the log lines sorted by frequency
+func logPatterns(c *monitoring.Client, proxy int, from, to time.Time, query string, fields map[string]string, cfg *configuration.Configuration) error {
+
+	patterns, err := c.GetLogPatterns(proxy, from, to, query, fields, cfg.LogConf)
+	if err != nil {
+		return err
+	}
+
+	if len(patterns) == 0 {
+		fmt.Println("No logs found.")
+		return nil
+	}
+
+	lines := 0
+	for _, p := range patterns {
+		lines += p.Count
+	}
+
+	fmt.Println(utils.Tabulate([]string{"count", "first", "last", "pods", "template", "example"}, func() [][]string {
+		r := [][]string{}
+		for _, p := range patterns {
+			example := p.Example
+			if r := []rune(example); len(r) > 120 {
+				example = string(r[:117]) + "..."
+			}
+			r = append(r, []string{fmt.Sprintf("%d", p.Count), p.First.Format(time.RFC3339), p.Last.Format(time.RFC3339), strings.Join(p.Pods, ","), p.Template, example})
+		}
+		return r
+	}()))
+
+	fmt.Printf("\n> %d lines grouped into %d patterns.\n", lines, len(patterns))
+
+	return nil
+}
+
+// searchTraces lists the traces matching the parameters for each service
+func searchTraces(backend monitoring.TraceBackend, cfg *configuration.Configuration, params monitoring.TracingQueryParameters) error {
+
+	if len(cfg.Services) == 0 {
+		return fmt.Errorf("at least one --service is required to search traces")
+	}
+
+	traces := []monitoring.Trace{}
+	for _, service := range cfg.Services {
+
+		params.Service = service
+
+		res, err := backend.SearchTraces(params)
+		if err != nil {
+			return err
+		}
+
+		traces = append(traces, res...)
+	}
+
+	// Most recent first
+	sort.Slice(traces, func(i, j int) bool { return traces[i].Start().After(traces[j].Start()) })
+
+	if len(traces) == 0 {
+		fmt.Println("No traces found.")
+		return nil
+	}
+
+	fmt.Println(utils.Tabulate([]string{"trace", "service", "root operation", "start", "duration", "spans"}, func() [][]string {
+		r := [][]string{}
+		for _, t := range traces {
+			service, operation := "", ""
+			if root := t.Root(); root != nil {
+				service, operation = t.Service(*root), root.OperationName
+			}
+			r = append(r, []string{t.TraceID, service, operation, t.Start().Format(time.RFC3339), t.Duration().String(), fmt.Sprintf("%d", len(t.Spans))})
+		}
+		return r
+	}()))
+
+	fmt.Printf("\n> %d traces found. Run tracer [--stack <name>] --open <trace> to open one.\n", len(traces))
+
+	return nil
+}
+
+// exportTraces writes the traces with the given IDs in the export directory
+func exportTraces(backend monitoring.TraceBackend, cfg *configuration.Configuration, ids []string) error {
+
+	if err := os.MkdirAll(cfg.ExportDir, 0o755); err != nil {
+		return fmt.Errorf("unable to create export directory: %w", err)
+	}
+
+	for _, id := range ids {
+		if err := monitoring.CheckTraceID(id); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range ids {
+
+		trace, err := backend.GetTrace(id)
+		if err != nil {
+			return err
+		}
+
+		data, err := monitoring.ExportTrace(trace, cfg.ExportFormat)
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(cfg.ExportDir, fmt.Sprintf("%s.%s.json", id, cfg.ExportFormat))
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return fmt.Errorf("unable to write trace %s: %w", id, err)
+		}
+
+		fmt.Printf("> Trace %s exported to %s\n", id, path)
+	}
+
+	return nil
+}
+
+// diffTraces displays the structural differences between two traces
+func diffTraces(backend monitoring.TraceBackend, idA, idB string) error {
+
+	a, err := backend.GetTrace(idA)
+	if err != nil {
+		return err
+	}
+
+	b, err := backend.GetTrace(idB)
+	if err != nil {
+		return err
+	}
+
+	diff := monitoring.DiffTraces(a, b)
+
+	fmt.Println(utils.Tabulate([]string{"span", "a", "b", "delta", "changed tags"}, func() [][]string {
+		r := [][]string{}
+		for _, s := range diff.Spans {
+
+			durationA, durationB := "-", "-"
+			if s.A != nil {
+				durationA = s.A.Elapsed().String()
+			}
+			if s.B != nil {
+				durationB = s.B.Elapsed().String()
+			}
+
+			tags := []string{}
+			for _, t := range s.Tags {
+				tags = append(tags, fmt.Sprintf("%s: %q -> %q", t.Key, t.A, t.B))
+			}
+
+			r = append(r, []string{s.Path, durationA, durationB, s.Delta(), strings.Join(tags, ", ")})
+		}
+		return r
+	}()))
+
+	onlyA, onlyB := 0, 0
+	for _, s := range diff.Spans {
+		switch {
+		case s.B == nil:
+			onlyA++
+		case s.A == nil:
+			onlyB++
+		}
+	}
+
+	fmt.Printf("\n> a=%s (%s) b=%s (%s): %d spans only in a, %d spans only in b.\n", diff.A, a.Duration(), diff.B, b.Duration(), onlyA, onlyB)
+
+	return nil
+}
+
+// serviceGraph displays the caller to callee graph of the services
+func serviceGraph(backend monitoring.TraceBackend, cfg *configuration.Configuration, from, to time.Time, tags map[string]string) error {
+
+	var graph monitoring.Graph
+
+	switch cfg.GraphSource {
+
+	case "dependencies":
+
+		dependencies, ok := backend.(monitoring.DependencyBackend)
+		if !ok {
+			return fmt.Errorf("the tracing backend does not provide dependencies, use --graph-source traces")
+		}
+
+		if len(cfg.Identities) > 0 || cfg.Namespace != "" {
+			zap.L().Warn("--identity and --namespace are ignored with --graph-source dependencies")
+		}
+
+		var err error
+		if graph, err = dependencies.Dependencies(to, to.Sub(from)); err != nil {
+			return err
+		}
+
+	default:
+
+		warnRecursiveTraces(cfg)
+
+		identities, err := utils.ParseIdentities(cfg.Identities)
+		if err != nil {
+			return err
+		}
+
+		services := cfg.Services
+		if len(services) == 0 {
+			if services, err = backend.ListServices(); err != nil {
+				return err
+			}
+		}
+
+		// An empty identity stands for any identity
+		if len(identities) == 0 {
+			identities = []string{""}
+		}
+
+		traces := []monitoring.Trace{}
+		for _, service := range services {
+			for _, identity := range identities {
+
+				params := tracingParameters(cfg, from, to, tags)
+				params.Service = service
+				params.Limit = cfg.GraphSample
+				if identity != "" {
+					params.Tags["req.identity"] = identity
+				}
+
+				res, err := backend.SearchTraces(params)
+				if err != nil {
+					return err
+				}
+
+				traces = append(traces, res...)
+			}
+		}
+
+		graph = monitoring.BuildGraph(traces)
+	}
+
+	graph = graph.Filter(cfg.Services...)
+
+	switch cfg.GraphFormat {
+
+	case "dot":
+		fmt.Print(graph.DOT())
+
+	case "mermaid":
+		fmt.Print(graph.Mermaid())
+
+	default:
+
+		if len(graph.Edges) == 0 {
+			fmt.Println("No calls found.")
+			return nil
+		}
+
+		fmt.Println(utils.Tabulate([]string{"caller", "callee", "calls", "errors", "avg latency"}, func() [][]string {
+			r := [][]string{}
+			for _, e := range graph.Edges {
+				errs, latency := "-", "-"
+				if !graph.CallsOnly {
+					errs, latency = fmt.Sprintf("%d", e.Errors), e.AvgLatency().Round(time.Millisecond).String()
+				}
+				r = append(r, []string{e.Caller, e.Callee, fmt.Sprintf("%d", e.Calls), errs, latency})
+			}
+			return r
+		}()))
+	}
+
+	return nil
+}